@@ -1,23 +1,120 @@
 package main
 
 import (
+	"consumer/internal/auth"
+	"consumer/internal/feed"
+	"consumer/internal/graph"
+	"consumer/internal/health"
+	"consumer/internal/models"
 	"consumer/internal/rest"
+	"consumer/internal/rest/handlers"
+	"consumer/internal/rpc"
 	"consumer/internal/services"
+	"consumer/internal/tracing"
+	"consumer/internal/utils"
+	"context"
 	"log"
 	"os"
+	"time"
 )
 
 func main() {
 	port := os.Getenv("PORT")
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "9090"
+	}
 	redisAddr := os.Getenv("REDIS_ADDR")
 	redisPw := os.Getenv("REDIS_PASSWORD")
+	maxStaleness := utils.GetEnvDuration("HEALTH_MAX_STALENESS", 5*time.Minute)
+
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Exporter:     os.Getenv("TRACING_EXPORTER"),
+		OtlpEndpoint: os.Getenv("TRACING_OTLP_ENDPOINT"),
+		ServiceName:  "api",
+	})
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
 
 	redisCfg := services.RedisConfig{Addr: redisAddr, Password: redisPw}
 	repo := services.NewRedisStatsRepo(redisCfg)
-	service := services.NewStatsService(repo)
+	service := services.NewStatsService(repo, services.CacheConfig{
+		BucketDivisor: utils.GetEnvInt("STATS_CACHE_BUCKET_DIVISOR", 60),
+	})
+
+	h := health.New(2 * time.Second)
+	h.AddReadinessCheck("redis", true, health.PingCheck(service.Ping))
+	h.AddReadinessCheck("freshness", false, health.FreshnessCheck(service.LastUpdate, maxStaleness))
+
+	streamCfg := handlers.StreamConfig{
+		HeartbeatInterval: utils.GetEnvPositiveDuration("SSE_HEARTBEAT_INTERVAL", 15*time.Second),
+		QueueSize:         utils.GetEnvPositiveInt("SSE_QUEUE_SIZE", 256),
+	}
+	// SSE streams are fed from the same Redis stream as the WebSocket gateway,
+	// the recent updates fill the replay buffer for clients resuming with Last-Event-ID
+	replaySize := utils.GetEnvInt("SSE_REPLAY_SIZE", 4096)
+	hub := feed.NewHub(service.SubscribeUpdates(context.Background(), int64(replaySize)), replaySize)
+	go hub.Run()
+
+	// Keys are configured as "name:key" entries and, when API_KEY_STORE is set, also looked up in Redis.
+	// Rate limits are in requests per second with bursts, shared by the replicas through Redis
+	accessRepo := services.NewRedisAccessRepo(redisCfg)
+	authCfg := auth.Config{
+		APIKeys:   os.Getenv("API_KEYS"),
+		JWTSecret: os.Getenv("API_JWT_SECRET"),
+	}
+	if utils.GetEnvBool("API_KEY_STORE", false) {
+		authCfg.KeyStore = accessRepo
+	}
+	authenticator, err := auth.New(authCfg)
+	if err != nil {
+		log.Fatalf("failed to initialize authentication: %v", err)
+	}
+	accessCfg := rest.AccessConfig{
+		Authenticator: authenticator,
+		KeyLimit: models.RateLimit{
+			Rate:  utils.GetEnvFloat("RATE_LIMIT_KEY_RATE", 10),
+			Burst: utils.GetEnvInt("RATE_LIMIT_KEY_BURST", 20),
+		},
+		IPLimit: models.RateLimit{
+			Rate:  utils.GetEnvFloat("RATE_LIMIT_IP_RATE", 20),
+			Burst: utils.GetEnvInt("RATE_LIMIT_IP_BURST", 40),
+		},
+		TrustedProxies: utils.GetEnvList("TRUSTED_PROXIES"),
+	}
+	if utils.GetEnvBool("RATE_LIMIT_ENABLED", true) {
+		accessCfg.Limiter = accessRepo
+	}
+
+	// GraphQL stats reads arriving within the batch wait of each other are read from Redis at once
+	graphCfg := graph.Config{
+		MaxDepth:  utils.GetEnvInt("GRAPHQL_MAX_DEPTH", 8),
+		BatchWait: utils.GetEnvDuration("GRAPHQL_BATCH_WAIT", 2*time.Millisecond),
+		QueueSize: utils.GetEnvPositiveInt("GRAPHQL_QUEUE_SIZE", 256),
+	}
+
+	// Internal services use the gRPC API next to the REST one, fed by the same hub and behind the same credentials and limits
+	rpcCfg := rpc.Config{
+		QueueSize:      utils.GetEnvPositiveInt("GRPC_QUEUE_SIZE", 256),
+		HealthInterval: utils.GetEnvPositiveDuration("GRPC_HEALTH_INTERVAL", 10*time.Second),
+	}
+	rpcAccessCfg := rpc.AccessConfig{
+		Authenticator: accessCfg.Authenticator,
+		Limiter:       accessCfg.Limiter,
+		KeyLimit:      accessCfg.KeyLimit,
+		IPLimit:       accessCfg.IPLimit,
+	}
+	rpcApi := rpc.New(grpcPort, service, h, hub, rpcCfg, rpcAccessCfg)
+	go func() {
+		if err := rpcApi.Run(); err != nil {
+			log.Fatalln(err)
+		}
+	}()
 
-	restApi := rest.New(port, service)
-	err := restApi.Run()
+	restApi := rest.New(port, service, h, hub, streamCfg, graphCfg, accessCfg)
+	err = restApi.Run()
 	if err != nil {
 		log.Println(err)
 	}
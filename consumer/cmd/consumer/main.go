@@ -2,17 +2,21 @@ package main
 
 import (
 	"consumer/internal/consumer"
+	"consumer/internal/health"
 	"consumer/internal/services"
-	"consumer/internal/ws"
+	"consumer/internal/tracing"
+	"consumer/internal/utils"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"time"
 )
 
 func main() {
-	wsPort := os.Getenv("WS_PORT")
+	port := os.Getenv("PORT")
 	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
 	kafkaTopic := os.Getenv("KAFKA_TOPIC")
 	kafkaConsumerGroupID := os.Getenv("KAFKA_CONSUMER_GROUP_ID")
@@ -24,42 +28,57 @@ func main() {
 		debug = true
 	}
 
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Exporter:     os.Getenv("TRACING_EXPORTER"),
+		OtlpEndpoint: os.Getenv("TRACING_OTLP_ENDPOINT"),
+		ServiceName:  "consumer",
+	})
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	var cfg = consumer.Config{
 		Brokers: kafkaBrokers,
 		Topic:   kafkaTopic,
 		GroupId: kafkaConsumerGroupID,
 		Debug:   debug,
+		MaxLag:  int64(utils.GetEnvInt("KAFKA_MAX_LAG", 1000)),
 	}
+	maxStaleness := utils.GetEnvDuration("HEALTH_MAX_STALENESS", 5*time.Minute)
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt)
 
 	redisCfg := services.RedisConfig{Addr: redisAddr, Password: redisPw}
 	repo := services.NewRedisStatsRepo(redisCfg)
-	service := services.NewStatsService(repo)
+	service := services.NewStatsService(repo, services.CacheConfig{})
 
-	var wsCh = make(chan []byte)
-	c, err := consumer.New(service, cfg, wsCh, sigCh)
+	c, err := consumer.New(service, cfg, sigCh)
 	if err != nil {
-		log.Printf("failed to initialize Kafka consumer: %v\n", err)
+		log.Fatalf("failed to initialize Kafka consumer: %v\n", err)
 	}
 	defer c.KafkaConsumer.Close()
 
-	ws := ws.New(wsCh)
-	http.HandleFunc("/ws", ws.Handler)
-	addr := fmt.Sprintf(":%s", wsPort)
+	h := health.New(2 * time.Second)
+	h.AddLivenessCheck("consumer_loop", c.CheckLoop)
+	h.AddReadinessCheck("redis", true, health.PingCheck(service.Ping))
+	h.AddReadinessCheck("kafka", true, c.CheckKafka)
+	h.AddReadinessCheck("freshness", false, health.FreshnessCheck(c.LastProcessed, maxStaleness))
+	http.HandleFunc("/healthz", h.Liveness)
+	http.HandleFunc("/readyz", h.Readiness)
+	addr := fmt.Sprintf(":%s", port)
 
 	go func() {
-		log.Printf("WebSocket server started on %s\n", addr)
+		log.Printf("Health check server started on %s\n", addr)
 		err = http.ListenAndServe(addr, nil)
 		if err != nil {
-			log.Printf("Error starting WebSocket server: %v\n", err)
+			log.Printf("Error starting health check server: %v\n", err)
 		}
-		log.Println("WebSocket server stopped")
+		log.Println("Health check server stopped")
 	}()
 
-	// Start web-socket broadcasting and consuming events from kafka
-	go ws.HandleBroadcasting()
+	// Start consuming events from kafka, the stats updates are published to the web-socket gateways
 	go c.ProcessSwapEvents()
 
 	<-sigCh
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"consumer/internal/auth"
+	"consumer/internal/feed"
+	"consumer/internal/health"
+	"consumer/internal/services"
+	"consumer/internal/tracing"
+	"consumer/internal/utils"
+	"consumer/internal/ws"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// WebSocket gateway broadcasting the stats updates published by all consumer replicas
+func main() {
+	port := os.Getenv("PORT")
+	redisAddr := os.Getenv("REDIS_ADDR")
+	redisPw := os.Getenv("REDIS_PASSWORD")
+	maxStaleness := utils.GetEnvDuration("HEALTH_MAX_STALENESS", 5*time.Minute)
+
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Exporter:     os.Getenv("TRACING_EXPORTER"),
+		OtlpEndpoint: os.Getenv("TRACING_OTLP_ENDPOINT"),
+		ServiceName:  "ws",
+	})
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	redisCfg := services.RedisConfig{Addr: redisAddr, Password: redisPw}
+	repo := services.NewRedisStatsRepo(redisCfg)
+	service := services.NewStatsService(repo, services.CacheConfig{}) // snapshots must include every update up to their seq
+
+	authenticator, err := auth.New(auth.Config{
+		APIKeys:   os.Getenv("WS_API_KEYS"),
+		JWTSecret: os.Getenv("WS_JWT_SECRET"),
+	})
+	if err != nil {
+		log.Fatalf("failed to initialize authentication: %v", err)
+	}
+
+	wsCfg := ws.Config{
+		SendQueueSize: utils.GetEnvPositiveInt("WS_SEND_QUEUE_SIZE", 256),
+		WriteTimeout:  utils.GetEnvPositiveDuration("WS_WRITE_TIMEOUT", 5*time.Second),
+		PingInterval:  utils.GetEnvPositiveDuration("WS_PING_INTERVAL", 30*time.Second),
+		PongTimeout:   utils.GetEnvPositiveDuration("WS_PONG_TIMEOUT", 60*time.Second),
+		Compression:   utils.GetEnvBool("WS_COMPRESSION", true),
+
+		Authenticator:  authenticator,
+		AllowedOrigins: utils.GetEnvList("WS_ALLOWED_ORIGINS"),
+		MaxConnsPerKey: utils.GetEnvInt("WS_MAX_CONNS_PER_KEY", 0),
+	}
+	replaySize := utils.GetEnvInt("WS_REPLAY_SIZE", 4096)
+	// The replay buffer is filled with the recent updates, so that clients can resume across gateway restarts
+	hub := feed.NewHub(service.SubscribeUpdates(ctx, int64(replaySize)), replaySize)
+	gateway := ws.New(hub, service, wsCfg)
+
+	h := health.New(2 * time.Second)
+	h.AddReadinessCheck("redis", true, health.PingCheck(service.Ping))
+	h.AddReadinessCheck("freshness", false, health.FreshnessCheck(hub.LastBroadcast, maxStaleness))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", gateway.Handler)
+	mux.HandleFunc("/healthz", h.Liveness)
+	mux.HandleFunc("/readyz", h.Readiness)
+	addr := fmt.Sprintf(":%s", port)
+
+	go func() {
+		log.Printf("WebSocket gateway started on %s\n", addr)
+		err := http.ListenAndServe(addr, mux)
+		if err != nil {
+			log.Printf("Error starting WebSocket gateway: %v\n", err)
+		}
+		log.Println("WebSocket gateway stopped")
+	}()
+
+	// The debug listing shows the principals and addresses of the clients, so it's served on an internal address only
+	debugAddr, ok := os.LookupEnv("WS_DEBUG_ADDR")
+	if !ok {
+		debugAddr = "127.0.0.1:6060"
+	}
+	if debugAddr != "" {
+		debugMux := http.NewServeMux()
+		debugMux.HandleFunc("/debug/ws/clients", gateway.DebugHandler)
+		go func() {
+			log.Printf("WebSocket gateway debug listing started on %s\n", debugAddr)
+			if err := http.ListenAndServe(debugAddr, debugMux); err != nil {
+				log.Printf("Error starting WebSocket gateway debug listing: %v\n", err)
+			}
+		}()
+	}
+
+	go hub.Run()
+
+	<-ctx.Done()
+	log.Println("Shutting down gracefully...")
+}
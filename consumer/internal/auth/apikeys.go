@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Header and query parameter the API key is read from,
+// the query parameter is for browsers that can't set headers on WebSocket connections
+const (
+	APIKeyHeader = "X-API-Key"
+	APIKeyParam  = "api_key"
+)
+
+// Static set of API keys, looked up by their hash so that the lookup time doesn't leak the keys.
+// Keys missing from the set are looked up in the store if there is one
+type APIKeys struct {
+	names map[[sha256.Size]byte]string
+	store KeyStore
+}
+
+// Parse comma-separated "name:key" entries
+func ParseAPIKeys(entries string) (*APIKeys, error) {
+	keys := &APIKeys{names: make(map[[sha256.Size]byte]string)}
+	for i, entry := range strings.Split(entries, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(entry, ":")
+		if !ok || name == "" || key == "" {
+			// The entry itself isn't reported, since it may be a bare key
+			return nil, errors.Errorf("invalid API key entry #%d, expected name:key", i+1)
+		}
+		keys.names[sha256.Sum256([]byte(key))] = name
+	}
+	return keys, nil
+}
+
+func (k *APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		key = r.URL.Query().Get(APIKeyParam)
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	hash := sha256.Sum256([]byte(key))
+	name, ok := k.names[hash]
+	if !ok && k.store != nil {
+		var err error
+		name, err = k.store.LookupAPIKey(r.Context(), hex.EncodeToString(hash[:]))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to look up the API key in the store")
+		}
+		ok = name != ""
+	}
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidCredentials, "unknown API key")
+	}
+	return &Principal{ID: name, Method: MethodAPIKey}, nil
+}
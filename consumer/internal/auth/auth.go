@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// Authenticator accepting the configured or stored API keys and the tokens signed with the secret.
+// Returns nil when none of them are configured, i.e. authentication is disabled
+func New(cfg Config) (Authenticator, error) {
+	var authenticators Chain
+	if cfg.APIKeys != "" || cfg.KeyStore != nil {
+		keys, err := ParseAPIKeys(cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		keys.store = cfg.KeyStore
+		authenticators = append(authenticators, keys)
+	}
+	if cfg.JWTSecret != "" {
+		authenticators = append(authenticators, NewJWT([]byte(cfg.JWTSecret)))
+	}
+	if len(authenticators) == 0 {
+		return nil, nil
+	}
+	return authenticators, nil
+}
+
+// Authenticators tried in order until one finds its credentials in the request
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		principal, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// Message reporting the authentication error to the client without the details of the failure
+func ErrorMessage(err error) string {
+	if errors.Is(err, ErrNoCredentials) {
+		return ErrNoCredentials.Error()
+	}
+	return ErrInvalidCredentials.Error()
+}
+
+// Query parameters the credentials can be sent in
+var QueryParams = []string{APIKeyParam, TokenParam}
+
+// Copy of the request without the credentials in the query, for the endpoints accepting them in the headers only
+func WithoutQueryCredentials(r *http.Request) *http.Request {
+	query := r.URL.Query()
+	if !HasQueryCredentials(query) {
+		return r
+	}
+	for _, param := range QueryParams {
+		query.Del(param)
+	}
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = query.Encode()
+	return r
+}
+
+// Whether the query carries credentials
+func HasQueryCredentials(query url.Values) bool {
+	for _, param := range QueryParams {
+		if query.Has(param) {
+			return true
+		}
+	}
+	return false
+}
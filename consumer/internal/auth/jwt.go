@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+// Query parameter the token is read from when there is no Authorization header
+const TokenParam = "access_token"
+
+// Allowed clock skew between the token issuer and the server
+const jwtLeeway = 30 * time.Second
+
+// HMAC-signed JWTs verified locally with the shared secret.
+// Tokens must expire and name the client in the subject claim
+type JWT struct {
+	secret []byte
+	parser *jwt.Parser
+}
+
+func NewJWT(secret []byte) *JWT {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	)
+	return &JWT{secret, parser}
+}
+
+func (j *JWT) Authenticate(r *http.Request) (*Principal, error) {
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		raw = r.URL.Query().Get(TokenParam)
+	}
+	if raw == "" {
+		return nil, ErrNoCredentials
+	}
+
+	var claims jwt.RegisteredClaims
+	_, err := j.parser.ParseWithClaims(raw, &claims, func(*jwt.Token) (any, error) {
+		return j.secret, nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidCredentials, err.Error())
+	}
+	if claims.Subject == "" {
+		return nil, errors.Wrap(ErrInvalidCredentials, "token has no subject")
+	}
+	return &Principal{ID: claims.Subject, Method: MethodJWT}, nil
+}
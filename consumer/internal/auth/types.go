@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Methods a client can authenticate with
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials provided")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Identity of an authenticated client
+type Principal struct {
+	ID     string `json:"id"`     // name of the API key or subject of the token
+	Method string `json:"method"` // api_key or jwt
+}
+
+// Method and ID, unique across the authentication methods
+func (p *Principal) String() string {
+	return p.Method + ":" + p.ID
+}
+
+// Authenticates the client making the request.
+// Returns ErrNoCredentials when the request carries none of the credentials it accepts,
+// and ErrInvalidCredentials when they are wrong, expired or malformed
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Store of the API keys issued at runtime, e.g. services.RedisAccessRepo.
+// Looks up the name of a key by the hex-encoded SHA-256 hash of the key, empty when the key isn't stored
+type KeyStore interface {
+	LookupAPIKey(ctx context.Context, hash string) (string, error)
+}
+
+type Config struct {
+	APIKeys   string   // comma-separated "name:key" entries, the name identifies the client in logs and limits
+	KeyStore  KeyStore // looks up the keys missing from APIKeys, only the configured keys are accepted when nil
+	JWTSecret string   // HMAC secret the tokens are signed with, tokens are rejected when empty
+}
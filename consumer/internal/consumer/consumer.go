@@ -3,30 +3,45 @@ package consumer
 import (
 	"consumer/internal/models"
 	"consumer/internal/services"
+	"consumer/internal/tracing"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("consumer/internal/consumer")
+
+// How long a single poll waits for a message before checking for shutdown
+const pollTimeout = time.Second
+
+// The consume loop is considered stuck if it hasn't polled for that long
+const maxPollInterval = 30 * time.Second
+
 type Client struct {
 	statsService  *services.StatsService
 	cfg           Config
 	KafkaConsumer *kafka.Consumer
-	wsCh          chan []byte
 	sigCh         chan os.Signal
+	lastPoll      atomic.Int64 // unix nanoseconds
+	lastProcessed atomic.Int64 // unix nanoseconds
 }
 
 func New(
 	statsService *services.StatsService,
 	cfg Config,
-	wsCh chan []byte,
 	sigCh chan os.Signal,
 ) (*Client, error) {
 	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
@@ -42,18 +57,24 @@ func New(
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to subscribe to a topic %s", cfg.Topic)
 	}
-	return &Client{statsService, cfg, consumer, wsCh, sigCh}, nil
+	return &Client{
+		statsService:  statsService,
+		cfg:           cfg,
+		KafkaConsumer: consumer,
+		sigCh:         sigCh,
+	}, nil
 }
 
 // Consume from Kafka and process stats
 func (c *Client) ProcessSwapEvents() {
 	for {
+		c.lastPoll.Store(time.Now().UnixNano())
 		select {
 		case <-c.sigCh:
 			log.Println("Received shutdown signal, stopping consumer...")
 			return
 		default:
-			msg, err := c.KafkaConsumer.ReadMessage(-1)
+			msg, err := c.KafkaConsumer.ReadMessage(pollTimeout)
 			if err != nil {
 				if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrTimedOut {
 					continue
@@ -62,22 +83,140 @@ func (c *Client) ProcessSwapEvents() {
 				continue
 			}
 
-			var event models.SwapEvent
-			err = json.Unmarshal(msg.Value, &event)
-			if err != nil {
-				log.Printf("failed to unmarshal message: %v\n", err)
-			}
+			c.processMessage(msg)
+		}
+	}
+}
 
-			err = c.statsService.ProcessSwapEvent(context.Background(), event, c.wsCh)
-			if err != nil {
-				log.Printf("failed to process swap event with tx hash %s: %v\n", event.TxHash, err)
-			}
+// Process a single Kafka message in a span that continues the trace started by the producer
+func (c *Client) processMessage(msg *kafka.Message) {
+	ctx := tracing.ExtractKafkaHeaders(context.Background(), msg)
+	ctx, span := tracer.Start(ctx, "swap.consume",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", *msg.TopicPartition.Topic),
+			attribute.Int("messaging.kafka.destination.partition", int(msg.TopicPartition.Partition)),
+			attribute.Int64("messaging.kafka.message.offset", int64(msg.TopicPartition.Offset)),
+		),
+	)
+	defer span.End()
 
-			if c.cfg.Debug {
-				logSwapEvent(event, msg)
-			}
+	event, err := decodeSwapEvent(ctx, msg.Value)
+	if err != nil {
+		log.Printf("failed to decode message: %v\n", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid swap event")
+		return
+	}
+	span.SetAttributes(attribute.String("swap.tx_hash", event.TxHash))
+
+	err = c.statsService.ProcessSwapEvent(ctx, event)
+	if err != nil {
+		log.Printf("failed to process swap event with tx hash %s: %v\n", event.TxHash, err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to process swap event")
+	} else {
+		c.lastProcessed.Store(time.Now().UnixNano())
+	}
+
+	if c.cfg.Debug {
+		logSwapEvent(event, msg)
+	}
+}
+
+// Unmarshal and validate the swap event from the message payload
+func decodeSwapEvent(ctx context.Context, payload []byte) (models.SwapEvent, error) {
+	_, span := tracer.Start(ctx, "swap.validate")
+	defer span.End()
+
+	var event models.SwapEvent
+	err := json.Unmarshal(payload, &event)
+	if err != nil {
+		span.RecordError(err)
+		return event, errors.Wrap(err, "failed to unmarshal message")
+	}
+
+	switch {
+	case event.TokenFrom == "" || event.TokenTo == "":
+		err = errors.Errorf("swap event with tx hash %s has no tokens", event.TxHash)
+	case event.TokenFrom == event.TokenTo:
+		err = errors.Errorf("swap event with tx hash %s swaps %s to itself", event.TxHash, event.TokenFrom)
+	case event.UsdValue < 0:
+		err = errors.Errorf("swap event with tx hash %s has negative usd value %v", event.TxHash, event.UsdValue)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid swap event")
+	}
+	return event, err
+}
+
+// Liveness check of the consume loop
+func (c *Client) CheckLoop(ctx context.Context) (map[string]any, error) {
+	sincePoll := time.Since(time.Unix(0, c.lastPoll.Load()))
+	details := map[string]any{"since_last_poll_seconds": sincePoll.Seconds()}
+	if sincePoll > maxPollInterval {
+		return details, errors.Errorf("consume loop hasn't polled for %s", sincePoll.Truncate(time.Second))
+	}
+	return details, nil
+}
+
+// Time of the last successfully processed swap event, zero time if there were none yet
+func (c *Client) LastProcessed(ctx context.Context) (time.Time, error) {
+	ns := c.lastProcessed.Load()
+	if ns == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(0, ns), nil
+}
+
+// Readiness check reporting the partitions assigned to the consumer and their lag
+func (c *Client) CheckKafka(ctx context.Context) (map[string]any, error) {
+	partitions, err := c.KafkaConsumer.Assignment()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get assigned partitions")
+	}
+	details := map[string]any{"assigned_partitions": len(partitions)}
+	// No assignment is fine for a replica idling while the group has more members than partitions
+	if len(partitions) == 0 {
+		return details, nil
+	}
+
+	positions, err := c.KafkaConsumer.Position(partitions)
+	if err != nil {
+		return details, errors.Wrap(err, "failed to get positions of assigned partitions")
+	}
+
+	timeoutMs := int(time.Second.Milliseconds())
+	if deadline, ok := ctx.Deadline(); ok {
+		timeoutMs = int(time.Until(deadline).Milliseconds())
+	}
+
+	var totalLag int64
+	partitionLag := make(map[string]int64, len(positions))
+	for _, p := range positions {
+		low, high, err := c.KafkaConsumer.QueryWatermarkOffsets(*p.Topic, p.Partition, timeoutMs)
+		if err != nil {
+			return details, errors.Wrapf(err, "failed to query watermark offsets of partition %d", p.Partition)
+		}
+		// Nothing consumed from the partition yet
+		offset := int64(p.Offset)
+		if offset < 0 {
+			offset = low
 		}
+		lag := max(high-offset, 0)
+		partitionLag[strconv.Itoa(int(p.Partition))] = lag
+		totalLag += lag
+	}
+	details["lag"] = totalLag
+	details["partition_lag"] = partitionLag
+	details["max_lag"] = c.cfg.MaxLag
+
+	if c.cfg.MaxLag > 0 && totalLag > c.cfg.MaxLag {
+		return details, errors.Errorf("lag %d exceeds the limit of %d", totalLag, c.cfg.MaxLag)
 	}
+	return details, nil
 }
 
 func logSwapEvent(event models.SwapEvent, msg *kafka.Message) {
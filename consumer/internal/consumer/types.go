@@ -5,4 +5,5 @@ type Config struct {
 	Topic   string
 	GroupId string
 	Debug   bool
+	MaxLag  int64 // readiness fails when the total lag of the assigned partitions exceeds it, 0 disables the limit
 }
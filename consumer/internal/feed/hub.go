@@ -0,0 +1,125 @@
+package feed
+
+import (
+	"consumer/internal/models"
+	"consumer/internal/tracing"
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("consumer/internal/feed")
+
+// Fan-out of the stats updates to the streams served by a process, e.g. WebSocket sessions or SSE responses.
+// Every update is encoded once, kept in the replay buffer for resuming clients and handed to all listeners
+type Hub struct {
+	updates       <-chan models.StatsUpdate
+	replay        *replayBuffer
+	mu            sync.Mutex
+	listeners     map[uint64]func(Entry)
+	lastID        uint64
+	lastSeq       atomic.Uint64 // seq of the last broadcast update
+	lastBroadcast atomic.Int64  // unix nanoseconds
+}
+
+func NewHub(updates <-chan models.StatsUpdate, replaySize int) *Hub {
+	return &Hub{
+		updates:   updates,
+		replay:    newReplayBuffer(replaySize),
+		listeners: make(map[uint64]func(Entry)),
+	}
+}
+
+// Broadcast the updates until their channel is closed
+func (h *Hub) Run() {
+	for update := range h.updates {
+		h.broadcast(update)
+	}
+}
+
+// Broadcast the update in a span linked to the one that published it, so that the trace of a swap
+// leads to its broadcasts on every replica even though they happen in other processes
+func (h *Hub) broadcast(update models.StatsUpdate) {
+	var links []trace.Link
+	if published := tracing.SpanContextOf(update.TraceParent); published.IsValid() {
+		links = append(links, trace.Link{SpanContext: published})
+	}
+	_, span := tracer.Start(context.Background(), "stats.broadcast",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.String("stats.key", update.Key),
+			attribute.String("stats.window", update.Window),
+			attribute.Int64("stats.seq", int64(update.Seq)),
+		),
+	)
+	defer span.End()
+
+	envelope := NewUpdateMessage(update)
+	message, err := json.Marshal(envelope)
+	if err != nil {
+		span.RecordError(err)
+		log.Printf("Error marshaling update of key %s: %v", update.Key, err)
+		return
+	}
+	entry := Entry{Update: update, Envelope: envelope, Message: message}
+	h.replay.add(entry)
+	h.lastSeq.Store(update.Seq)
+	h.lastBroadcast.Store(time.Now().UnixNano())
+
+	// The lock is held just to copy the listeners, so that subscribing never waits for the fan-out
+	h.mu.Lock()
+	listeners := make([]func(Entry), 0, len(h.listeners))
+	for _, fn := range h.listeners {
+		listeners = append(listeners, fn)
+	}
+	h.mu.Unlock()
+
+	span.SetAttributes(attribute.Int("feed.listeners", len(listeners)))
+	for _, fn := range listeners {
+		fn(entry)
+	}
+}
+
+// Call the function with every update broadcast from now on until the returned cancel function is called.
+// The function runs in the broadcasting goroutine, so it must not block
+func (h *Hub) Listen(fn func(Entry)) func() {
+	h.mu.Lock()
+	h.lastID++
+	id := h.lastID
+	h.listeners[id] = fn
+	h.mu.Unlock()
+
+	return func() {
+		h.mu.Lock()
+		delete(h.listeners, id)
+		h.mu.Unlock()
+	}
+}
+
+// Seq of the last broadcast update, 0 if there were none yet
+func (h *Hub) LastSeq() uint64 {
+	return h.lastSeq.Load()
+}
+
+// Buffered updates after the seq in the broadcast order.
+// Returns false when some of them aren't buffered anymore
+func (h *Hub) Since(seq uint64) ([]Entry, bool) {
+	return h.replay.since(seq, h.lastSeq.Load())
+}
+
+// Time the last update was broadcast at, zero time if there were none yet
+func (h *Hub) LastBroadcast(ctx context.Context) (time.Time, error) {
+	ns := h.lastBroadcast.Load()
+	if ns == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(0, ns), nil
+}
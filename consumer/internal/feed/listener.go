@@ -0,0 +1,111 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Deadline for reading the current stats of a snapshot
+const SnapshotTimeout = 5 * time.Second
+
+// Keys subscribed to when none are requested
+var AllKeys = []string{"*", "*-*"}
+
+// Broadcast updates matching the subscriptions of a single stream, e.g. an SSE response or a gRPC call,
+// queued until the stream writes them. A stream that lets the queue overflow can't keep up and should end
+type Listener struct {
+	queue    chan Entry
+	overflow chan struct{}
+	cancel   func()
+}
+
+// Queue the updates matching the subscriptions broadcast from now on, until the listener is closed.
+// Subscribe before loading the initial messages, so that no update falls in between
+func (h *Hub) Subscribe(subs []Subscription, queueSize int) *Listener {
+	matching := make(Subscriptions)
+	matching.Add(subs)
+	l := &Listener{
+		queue:    make(chan Entry, queueSize),
+		overflow: make(chan struct{}),
+	}
+	var overflowOnce sync.Once
+	l.cancel = h.Listen(func(entry Entry) {
+		if !matching.Matches(entry.Update.Key, entry.Update.Window) {
+			return
+		}
+		select {
+		case l.queue <- entry:
+		default:
+			overflowOnce.Do(func() { close(l.overflow) })
+		}
+	})
+	return l
+}
+
+// Queued updates in the broadcast order
+func (l *Listener) Updates() <-chan Entry {
+	return l.queue
+}
+
+// Closed once an update didn't fit in the queue
+func (l *Listener) Overflow() <-chan struct{} {
+	return l.overflow
+}
+
+func (l *Listener) QueueSize() int {
+	return cap(l.queue)
+}
+
+// Stop queueing the updates
+func (l *Listener) Close() {
+	l.cancel()
+}
+
+// Messages a stream starts with. Replay holds the missed updates matching the subscriptions when resuming is possible,
+// Messages the snapshot otherwise, preceded by a resync alert when resuming was requested.
+// Seq is the seq of the last update they cover, queued updates up to it are skipped
+type Initial struct {
+	Resumed  bool
+	Replay   []Entry
+	Messages []Message
+	Seq      uint64
+}
+
+// Load the missed updates after the last seq if they are all buffered, the snapshot of the subscriptions otherwise.
+// The last seq is nil for streams that aren't resuming
+func LoadInitial(ctx context.Context, hub *Hub, stats StatsReader, subs []Subscription, lastSeq *uint64) (*Initial, error) {
+	initial := &Initial{}
+	if lastSeq != nil {
+		if entries, ok := hub.Since(*lastSeq); ok {
+			matching := make(Subscriptions)
+			matching.Add(subs)
+			initial.Resumed = true
+			initial.Seq = *lastSeq
+			for _, entry := range entries {
+				initial.Seq = entry.Update.Seq
+				if matching.Matches(entry.Update.Key, entry.Update.Window) {
+					initial.Replay = append(initial.Replay, entry)
+				}
+			}
+			return initial, nil
+		}
+
+		detail := fmt.Sprintf("updates after seq %d are not available, sending a fresh snapshot", *lastSeq)
+		initial.Messages = append(initial.Messages, NewAlertMessage(AlertResyncRequired, detail))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, SnapshotTimeout)
+	defer cancel()
+
+	initial.Seq = hub.LastSeq()
+	snapshot, err := LoadSnapshot(ctx, stats, subs, initial.Seq)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load snapshot")
+	}
+	initial.Messages = append(initial.Messages, snapshot...)
+	return initial, nil
+}
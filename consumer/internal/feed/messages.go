@@ -0,0 +1,46 @@
+package feed
+
+import (
+	"consumer/internal/models"
+	"time"
+)
+
+// Message of the type stamped with the protocol version and the current time
+func NewMessage(messageType string) Message {
+	return Message{Version: ProtocolVersion, Type: messageType, Timestamp: time.Now().UTC()}
+}
+
+func NewUpdateMessage(update models.StatsUpdate) Message {
+	message := NewMessage(TypeUpdate)
+	message.Seq = update.Seq
+	message.PrevSeq = update.PrevSeq
+	message.Key = update.Key
+	message.Window = update.Window
+	message.Stats = update.Stats
+	message.TxHash = update.TxHash
+	return message
+}
+
+func NewSnapshotMessage(seq uint64, key, window string, stats *models.Stats) Message {
+	message := NewMessage(TypeSnapshot)
+	message.Seq = seq
+	message.Key = key
+	message.Window = window
+	message.Stats = stats
+	return message
+}
+
+func NewErrorMessage(id, action, err string) Message {
+	message := NewMessage(TypeError)
+	message.ID = id
+	message.Action = action
+	message.Error = err
+	return message
+}
+
+func NewAlertMessage(code, detail string) Message {
+	message := NewMessage(TypeAlert)
+	message.Code = code
+	message.Detail = detail
+	return message
+}
@@ -0,0 +1,50 @@
+package feed
+
+import (
+	"consumer/internal/models"
+	statsv1 "consumer/proto/stats/v1"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Message as stats.v1.StreamMessage, sent to WebSocket clients using protobuf and to gRPC subscribers
+func (m Message) Proto() *statsv1.StreamMessage {
+	pb := &statsv1.StreamMessage{
+		V:       uint32(m.Version),
+		Type:    m.Type,
+		Seq:     m.Seq,
+		PrevSeq: m.PrevSeq,
+		Ts:      timestamppb.New(m.Timestamp),
+		Key:     m.Key,
+		Window:  m.Window,
+		TxHash:  m.TxHash,
+		Id:      m.ID,
+		Action:  m.Action,
+		Error:   m.Error,
+		Code:    m.Code,
+		Detail:  m.Detail,
+	}
+	if m.Stats != nil {
+		pb.Stats = StatsProto(m.Stats)
+	}
+	for _, sub := range m.Subscriptions {
+		pb.Subscriptions = append(pb.Subscriptions, &statsv1.Subscription{Key: sub.Key, Window: sub.Window})
+	}
+	return pb
+}
+
+// Stats as stats.v1.Stats, nil stays nil
+func StatsProto(stats *models.Stats) *statsv1.Stats {
+	if stats == nil {
+		return nil
+	}
+	pb := &statsv1.Stats{Volume: stats.Volume, TxCount: stats.TxCount, Amounts: stats.Amounts}
+	if stats.Flows != nil {
+		pb.Flows = &statsv1.Flows{
+			SoldVolume:   stats.SoldVolume,
+			BoughtVolume: stats.BoughtVolume,
+			NetFlow:      stats.NetFlow,
+		}
+	}
+	return pb
+}
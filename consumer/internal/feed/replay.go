@@ -0,0 +1,56 @@
+package feed
+
+import "sync"
+
+// Bounded ring buffer of the recent updates, so that reconnecting clients can catch up
+type replayBuffer struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int // index the next entry is written at
+	count   int
+}
+
+func newReplayBuffer(size int) *replayBuffer {
+	return &replayBuffer{entries: make([]Entry, max(size, 1))}
+}
+
+func (b *replayBuffer) add(entry Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	b.count = min(b.count+1, len(b.entries))
+}
+
+// Entries with seq greater than the given one in the broadcast order.
+// Returns false when some of them were already overwritten or were never broadcast by this server,
+// e.g. when the client comes from another replica or from before a restart
+func (b *replayBuffer) since(seq, lastSeq uint64) ([]Entry, bool) {
+	if seq > lastSeq {
+		return nil, false
+	}
+	if seq == lastSeq {
+		return nil, true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.count == 0 {
+		return nil, false
+	}
+	oldest := (b.next - b.count + len(b.entries)) % len(b.entries)
+	if b.entries[oldest].Update.Seq > seq+1 {
+		return nil, false
+	}
+
+	var entries []Entry
+	for i := 0; i < b.count; i++ {
+		entry := b.entries[(oldest+i)%len(b.entries)]
+		if entry.Update.Seq > seq {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, true
+}
@@ -0,0 +1,43 @@
+package feed
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Read the current stats of every key and window covered by the subscriptions in a single batch,
+// keys without swaps in the window are snapshotted with zero stats.
+// Every snapshot message carries the seq of the last update broadcast before the stats were read
+func LoadSnapshot(ctx context.Context, stats StatsReader, subs []Subscription, seq uint64) ([]Message, error) {
+	loaded := make(map[Subscription]bool)
+	var keySubs []Subscription
+	var statsKeys []string
+	for _, sub := range subs {
+		for _, key := range ExpandPattern(sub.Key) {
+			keySub := Subscription{Key: key, Window: sub.Window}
+			if loaded[keySub] {
+				continue
+			}
+			loaded[keySub] = true
+			keySubs = append(keySubs, keySub)
+			statsKeys = append(statsKeys, "stats:"+key+":"+sub.Window)
+		}
+	}
+	if len(statsKeys) == 0 {
+		return nil, nil
+	}
+
+	keyStats, keyErrs, err := stats.GetStatsBatch(ctx, statsKeys)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load snapshot of %d keys", len(statsKeys))
+	}
+	snapshot := make([]Message, 0, len(keySubs))
+	for i, keySub := range keySubs {
+		if err := keyErrs[statsKeys[i]]; err != nil {
+			return nil, errors.Wrapf(err, "failed to load snapshot of %s in %s window", keySub.Key, keySub.Window)
+		}
+		snapshot = append(snapshot, NewSnapshotMessage(seq, keySub.Key, keySub.Window, keyStats[statsKeys[i]]))
+	}
+	return snapshot, nil
+}
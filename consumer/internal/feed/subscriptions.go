@@ -0,0 +1,145 @@
+package feed
+
+import (
+	"consumer/internal/models"
+	"consumer/internal/services"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const wildcard = "*"
+
+// Validate the requested keys and windows and expand them into subscriptions
+func BuildSubscriptions(keys, reqWindows []string) ([]Subscription, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys provided")
+	}
+	if len(reqWindows) == 0 {
+		reqWindows = models.Windows
+	}
+	for _, window := range reqWindows {
+		if !services.IsValidPeriod(window) {
+			return nil, errors.Errorf("invalid period window %q", window)
+		}
+	}
+
+	var subs []Subscription
+	for _, key := range keys {
+		key = strings.ToUpper(key)
+		if !isValidPattern(key) {
+			return nil, errors.Errorf("invalid key %q", key)
+		}
+		for _, window := range reqWindows {
+			subs = append(subs, Subscription{Key: key, Window: window})
+		}
+	}
+	return subs, nil
+}
+
+// Pattern is either a token or a pair, where any token can be replaced with a wildcard
+func isValidPattern(pattern string) bool {
+	parts := strings.Split(pattern, "-")
+	if len(parts) > 2 {
+		return false
+	}
+	for _, part := range parts {
+		if part != wildcard && !services.IsValidToken(part) {
+			return false
+		}
+	}
+	return len(parts) == 1 || parts[0] != parts[1] || parts[0] == wildcard
+}
+
+// All token or pair keys the pattern matches
+func ExpandPattern(pattern string) []string {
+	if !strings.Contains(pattern, wildcard) {
+		return []string{pattern}
+	}
+
+	tokens := services.Tokens()
+	var keys []string
+	for _, t1 := range tokens {
+		if !strings.Contains(pattern, "-") {
+			keys = append(keys, t1)
+			continue
+		}
+		for _, t2 := range tokens {
+			key := t1 + "-" + t2
+			if t1 != t2 && matchesPattern(pattern, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// Check whether the token or pair key matches the pattern
+func matchesPattern(pattern, key string) bool {
+	if pattern == key {
+		return true
+	}
+	patternParts := strings.Split(pattern, "-")
+	keyParts := strings.Split(key, "-")
+	if len(patternParts) != len(keyParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		if part != wildcard && part != keyParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Set of subscriptions of a single client or stream
+type Subscriptions map[Subscription]bool
+
+func (s Subscriptions) Add(subs []Subscription) {
+	for _, sub := range subs {
+		s[sub] = true
+	}
+}
+
+func (s Subscriptions) Remove(subs []Subscription) {
+	for _, sub := range subs {
+		delete(s, sub)
+	}
+}
+
+// Check whether any of the subscriptions covers the key in the window
+func (s Subscriptions) Matches(key, window string) bool {
+	for sub := range s {
+		if sub.Window == window && matchesPattern(sub.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscriptions covering the key in the window
+func (s Subscriptions) Matching(key, window string) []Subscription {
+	var subs []Subscription
+	for sub := range s {
+		if sub.Window == window && matchesPattern(sub.Key, key) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// Subscriptions in a stable order to report them to the client
+func (s Subscriptions) List() []Subscription {
+	subs := make([]Subscription, 0, len(s))
+	for sub := range s {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].Key != subs[j].Key {
+			return subs[i].Key < subs[j].Key
+		}
+		return subs[i].Window < subs[j].Window
+	})
+	return subs
+}
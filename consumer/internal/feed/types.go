@@ -0,0 +1,71 @@
+package feed
+
+import (
+	"consumer/internal/models"
+	"context"
+	"time"
+)
+
+// Version of the message format, bumped on incompatible changes
+const ProtocolVersion = 1
+
+// Types of the messages sent to clients
+const (
+	TypeSnapshot = "snapshot" // current stats of a key and window, sent on subscribe
+	TypeUpdate   = "update"   // stats of a key and window changed by a swap
+	TypeAlert    = "alert"    // notice about the state of the stream
+	TypeAck      = "ack"      // control request was applied
+	TypeError    = "error"    // control request was rejected or failed
+)
+
+// Codes of the alerts
+const (
+	// Updates after the seq the client resumes from aren't available anymore,
+	// a fresh snapshot follows the alert
+	AlertResyncRequired = "resync_required"
+)
+
+// Envelope of every message sent to clients, shared by the WebSocket and SSE streams.
+// Seq is the position of an update in the stream of all keys, so the updates of the subscribed keys have gaps.
+// PrevSeq is the seq of the previous update of the same key and window: an update was missed
+// when it's greater than the seq of the last update or snapshot of the key and window the client got.
+// Snapshots carry the seq of the last update they include, other types have no seq.
+// Acknowledgements carry the subscriptions of the client after the request is applied,
+// alerts carry a code and a human-readable detail
+type Message struct {
+	Version       int            `json:"v"`
+	Type          string         `json:"type"`
+	Seq           uint64         `json:"seq,omitempty"`
+	PrevSeq       uint64         `json:"prev_seq,omitempty"`
+	Timestamp     time.Time      `json:"ts"`
+	Key           string         `json:"key,omitempty"`
+	Window        string         `json:"window,omitempty"`
+	Stats         *models.Stats  `json:"stats,omitempty"`
+	TxHash        string         `json:"tx_hash,omitempty"`
+	ID            string         `json:"id,omitempty"`
+	Action        string         `json:"action,omitempty"`
+	Subscriptions []Subscription `json:"subscriptions,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	Code          string         `json:"code,omitempty"`
+	Detail        string         `json:"detail,omitempty"`
+}
+
+// Subscription to the stats of a token or pair key in a period window.
+// The key is either a token "ETH", a pair "BTC-USDT" or a pattern where "*"
+// stands for any token: "*" (all tokens), "*-*" (all pairs), "ETH-*", "*-ETH"
+type Subscription struct {
+	Key    string `json:"key"`
+	Window string `json:"window"`
+}
+
+// Broadcast update along with its update message and the message encoded as JSON
+type Entry struct {
+	Update   models.StatsUpdate
+	Envelope Message
+	Message  []byte
+}
+
+// Source of the current stats for the snapshots, e.g. services.StatsService
+type StatsReader interface {
+	GetStatsBatch(ctx context.Context, keys []string) (map[string]*models.Stats, map[string]error, error)
+}
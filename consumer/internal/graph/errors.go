@@ -0,0 +1,37 @@
+package graph
+
+import (
+	"consumer/internal/rest/middleware"
+	"consumer/internal/services"
+	"log"
+
+	"github.com/pkg/errors"
+)
+
+// Error of a resolver, reported in the errors of the response with the problem code in its extensions
+type Error struct {
+	Message string
+	Code    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Extensions() map[string]any {
+	return map[string]any{"code": e.Code}
+}
+
+// Only the details of domain errors are shown to clients, other errors are logged
+func resolverError(err error) error {
+	code := middleware.ProblemCode(err)
+	var domainErr *services.Error
+	if !errors.As(err, &domainErr) {
+		log.Printf("GraphQL resolver failed: %v", err)
+		return &Error{Message: "internal error", Code: code}
+	}
+	if errors.Is(err, services.ErrStoreUnavailable) {
+		log.Printf("GraphQL resolver failed: %v", err)
+	}
+	return &Error{Message: domainErr.Detail, Code: code}
+}
@@ -0,0 +1,45 @@
+package graph
+
+import (
+	"consumer/internal/feed"
+	"consumer/internal/services"
+	"context"
+	_ "embed"
+
+	"github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// GraphQL schema over the tokens, pairs and their stats, with subscriptions to the stats updates of the hub
+type Graph struct {
+	schema *graphql.Schema
+	stats  *services.StatsService
+	cfg    Config
+}
+
+func New(stats *services.StatsService, hub *feed.Hub, cfg Config) *Graph {
+	opts := []graphql.SchemaOpt{graphql.UseFieldResolvers()}
+	if cfg.MaxDepth > 0 {
+		opts = append(opts, graphql.MaxDepth(cfg.MaxDepth))
+	}
+	root := &rootResolver{hub: hub, queueSize: cfg.QueueSize}
+	return &Graph{graphql.MustParseSchema(schema, root, opts...), stats, cfg}
+}
+
+// Execute a query, the stats it reads are batched into as few store reads as possible
+func (g *Graph) Exec(ctx context.Context, req Request) *graphql.Response {
+	return g.schema.Exec(g.withLoaders(ctx), req.Query, req.OperationName, req.Variables)
+}
+
+// Execute a subscription and stream its responses until the context is done or the subscription ends.
+// Queries are executed too, with their only response streamed
+func (g *Graph) Subscribe(ctx context.Context, req Request) (<-chan any, error) {
+	return g.schema.Subscribe(g.withLoaders(ctx), req.Query, req.OperationName, req.Variables)
+}
+
+// Loaders are per request, so that their cache never serves stale stats to later requests
+func (g *Graph) withLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loaderKey{}, newStatsLoader(g.stats, g.cfg.BatchWait))
+}
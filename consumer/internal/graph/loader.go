@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"consumer/internal/models"
+	"consumer/internal/services"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/pkg/errors"
+)
+
+type loaderKey struct{}
+
+type statsLoader = dataloader.Loader[string, *models.Stats]
+
+// Collect the stats reads of the resolvers and read every batch with a single GetStatsBatch
+func newStatsLoader(stats *services.StatsService, wait time.Duration) *statsLoader {
+	batch := func(ctx context.Context, keys []string) []*dataloader.Result[*models.Stats] {
+		results := make([]*dataloader.Result[*models.Stats], len(keys))
+		batchStats, keyErrs, err := stats.GetStatsBatch(ctx, keys)
+		for i, key := range keys {
+			switch {
+			case err != nil:
+				results[i] = &dataloader.Result[*models.Stats]{Error: err}
+			case keyErrs[key] != nil:
+				results[i] = &dataloader.Result[*models.Stats]{Error: errors.Wrapf(keyErrs[key], "failed to read window key %s", key)}
+			default:
+				results[i] = &dataloader.Result[*models.Stats]{Data: batchStats[key]}
+			}
+		}
+		return results
+	}
+	return dataloader.NewBatchedLoader(batch, dataloader.WithWait[string, *models.Stats](wait))
+}
+
+// Load the stats of a token or pair key in the window through the loader of the request
+func loadStats(ctx context.Context, key, window string) (*models.Stats, error) {
+	loader, ok := ctx.Value(loaderKey{}).(*statsLoader)
+	if !ok {
+		return nil, errors.New("no stats loader in the context")
+	}
+	statsKey := fmt.Sprintf("stats:%s:%s", key, window)
+	stats, err := loader.Load(ctx, statsKey)()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load stats for the key %s", statsKey)
+	}
+	if stats == nil {
+		// Keys without swaps in the window have zero stats
+		stats = &models.Stats{}
+	}
+	return stats, nil
+}
@@ -0,0 +1,250 @@
+package graph
+
+import (
+	"consumer/internal/feed"
+	"consumer/internal/models"
+	"consumer/internal/services"
+	"context"
+	"log"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+type rootResolver struct {
+	hub       *feed.Hub
+	queueSize int
+}
+
+func (r *rootResolver) Tokens() []*tokenResolver {
+	var tokens []*tokenResolver
+	for _, token := range services.Tokens() {
+		tokens = append(tokens, &tokenResolver{token})
+	}
+	return tokens
+}
+
+func (r *rootResolver) Token(args struct{ Symbol string }) (*tokenResolver, error) {
+	symbol := strings.ToUpper(args.Symbol)
+	if !services.IsValidToken(symbol) {
+		return nil, resolverError(services.NewError(services.ErrInvalidToken, "invalid token %q provided", args.Symbol))
+	}
+	return &tokenResolver{symbol}, nil
+}
+
+func (r *rootResolver) Pairs() []*pairResolver {
+	var pairs []*pairResolver
+	for _, from := range services.Tokens() {
+		for _, to := range services.Tokens() {
+			if from != to {
+				pairs = append(pairs, &pairResolver{from, to})
+			}
+		}
+	}
+	return pairs
+}
+
+func (r *rootResolver) Pair(args struct{ Key string }) (*pairResolver, error) {
+	key := strings.ToUpper(args.Key)
+	tokens := strings.Split(key, "-")
+	if !services.IsValidPair(key) || tokens[0] == tokens[1] {
+		return nil, resolverError(services.NewError(services.ErrInvalidPair, "invalid pair %q provided", args.Key))
+	}
+	return &pairResolver{tokens[0], tokens[1]}, nil
+}
+
+// Stream the matching updates broadcast by the hub from now on.
+// Subscriptions too slow to keep up with the updates are ended once their queue overflows
+func (r *rootResolver) StatsUpdated(ctx context.Context, args struct {
+	Keys    *[]string
+	Windows *[]string
+}) (<-chan *statsUpdateResolver, error) {
+	keys := feed.AllKeys
+	if args.Keys != nil && len(*args.Keys) > 0 {
+		keys = *args.Keys
+	}
+	var windows []string
+	if args.Windows != nil {
+		windows = *args.Windows
+	}
+	subs, err := feed.BuildSubscriptions(keys, windows)
+	if err != nil {
+		return nil, resolverError(services.NewError(services.ErrInvalidRequest, "%s", err.Error()))
+	}
+
+	listener := r.hub.Subscribe(subs, r.queueSize)
+	updates := make(chan *statsUpdateResolver)
+	go func() {
+		defer close(updates)
+		defer listener.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-listener.Overflow():
+				log.Printf("Ending slow GraphQL subscription: queue of %d updates is full", listener.QueueSize())
+				return
+			case entry := <-listener.Updates():
+				select {
+				case updates <- &statsUpdateResolver{entry.Update}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return updates, nil
+}
+
+type windowArgs struct {
+	Window string
+}
+
+type tokenResolver struct {
+	symbol string
+}
+
+func (r *tokenResolver) Symbol() string {
+	return r.symbol
+}
+
+func (r *tokenResolver) Stats(ctx context.Context, args windowArgs) (*statsResolver, error) {
+	return resolveStats(ctx, r.symbol, args.Window)
+}
+
+func (r *tokenResolver) Pairs() []*pairResolver {
+	var pairs []*pairResolver
+	for _, token := range services.Tokens() {
+		if token != r.symbol {
+			pairs = append(pairs, &pairResolver{r.symbol, token}, &pairResolver{token, r.symbol})
+		}
+	}
+	return pairs
+}
+
+type pairResolver struct {
+	from string
+	to   string
+}
+
+func (r *pairResolver) Key() string {
+	return r.from + "-" + r.to
+}
+
+func (r *pairResolver) From() *tokenResolver {
+	return &tokenResolver{r.from}
+}
+
+func (r *pairResolver) To() *tokenResolver {
+	return &tokenResolver{r.to}
+}
+
+func (r *pairResolver) Stats(ctx context.Context, args windowArgs) (*statsResolver, error) {
+	return resolveStats(ctx, r.Key(), args.Window)
+}
+
+func resolveStats(ctx context.Context, key, window string) (*statsResolver, error) {
+	if !services.IsValidPeriod(window) {
+		return nil, resolverError(services.NewError(services.ErrInvalidWindow, "invalid period window %q provided", window))
+	}
+	stats, err := loadStats(ctx, key, window)
+	if err != nil {
+		return nil, resolverError(err)
+	}
+	return &statsResolver{window, stats}, nil
+}
+
+type statsResolver struct {
+	window string
+	stats  *models.Stats
+}
+
+func (r *statsResolver) Window() string {
+	return r.window
+}
+
+func (r *statsResolver) Volume() float64 {
+	return r.stats.Volume
+}
+
+// GraphQL integers are 32-bit, counts beyond them are clamped
+func (r *statsResolver) TxCount() int32 {
+	return int32(min(r.stats.TxCount, math.MaxInt32))
+}
+
+func (r *statsResolver) SoldVolume() *float64 {
+	if r.stats.Flows == nil {
+		return nil
+	}
+	return &r.stats.SoldVolume
+}
+
+func (r *statsResolver) BoughtVolume() *float64 {
+	if r.stats.Flows == nil {
+		return nil
+	}
+	return &r.stats.BoughtVolume
+}
+
+func (r *statsResolver) NetFlow() *float64 {
+	if r.stats.Flows == nil {
+		return nil
+	}
+	return &r.stats.NetFlow
+}
+
+// Amounts in alphabetical order of the tokens
+func (r *statsResolver) Amounts() []*amountResolver {
+	amounts := make([]*amountResolver, 0, len(r.stats.Amounts))
+	for _, token := range slices.Sorted(maps.Keys(r.stats.Amounts)) {
+		amounts = append(amounts, &amountResolver{token, r.stats.Amounts[token]})
+	}
+	return amounts
+}
+
+type amountResolver struct {
+	token  string
+	amount float64
+}
+
+func (r *amountResolver) Token() string {
+	return r.token
+}
+
+func (r *amountResolver) Amount() float64 {
+	return r.amount
+}
+
+type statsUpdateResolver struct {
+	update models.StatsUpdate
+}
+
+// GraphQL integers are 32-bit, so the seq is a float that is exact up to 2^53
+func (r *statsUpdateResolver) Seq() float64 {
+	return float64(r.update.Seq)
+}
+
+func (r *statsUpdateResolver) PrevSeq() float64 {
+	return float64(r.update.PrevSeq)
+}
+
+func (r *statsUpdateResolver) Key() string {
+	return r.update.Key
+}
+
+func (r *statsUpdateResolver) Window() string {
+	return r.update.Window
+}
+
+func (r *statsUpdateResolver) Stats() *statsResolver {
+	stats := r.update.Stats
+	if stats == nil {
+		stats = &models.Stats{}
+	}
+	return &statsResolver{r.update.Window, stats}
+}
+
+func (r *statsUpdateResolver) TxHash() string {
+	return r.update.TxHash
+}
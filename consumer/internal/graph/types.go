@@ -0,0 +1,16 @@
+package graph
+
+import "time"
+
+type Config struct {
+	MaxDepth  int           // deepest selection a query may nest, unlimited when zero
+	BatchWait time.Duration // how long the stats reads of a query are collected into a single batch
+	QueueSize int           // updates buffered per subscription, the subscription is ended when its queue overflows
+}
+
+// Operation of a GraphQL request as posted by the clients
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
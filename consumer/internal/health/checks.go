@@ -0,0 +1,43 @@
+package health
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Check that the last update happened no longer than maxAge ago
+func FreshnessCheck(lastUpdate func(ctx context.Context) (time.Time, error), maxAge time.Duration) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		last, err := lastUpdate(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get the time of the last update")
+		}
+		if last.IsZero() {
+			return map[string]any{"max_age_seconds": maxAge.Seconds()}, errors.New("no updates yet")
+		}
+
+		age := time.Since(last)
+		details := map[string]any{
+			"last_update":     last.UTC(),
+			"age_seconds":     age.Seconds(),
+			"max_age_seconds": maxAge.Seconds(),
+		}
+		if age > maxAge {
+			return details, errors.Errorf("last update is %s old", age.Truncate(time.Second))
+		}
+		return details, nil
+	}
+}
+
+// Check that the dependency responds to a ping
+func PingCheck(ping func(ctx context.Context) error) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		start := time.Now()
+		if err := ping(ctx); err != nil {
+			return nil, errors.Wrap(err, "ping failed")
+		}
+		return map[string]any{"latency_ms": float64(time.Since(start).Microseconds()) / 1000}, nil
+	}
+}
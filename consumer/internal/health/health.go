@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Registry of liveness and readiness checks served as Kubernetes probe endpoints
+type Health struct {
+	timeout   time.Duration
+	startedAt time.Time
+	liveness  []check
+	readiness []check
+}
+
+// Each check is given at most timeout to complete
+func New(timeout time.Duration) *Health {
+	return &Health{timeout: timeout, startedAt: time.Now()}
+}
+
+// Liveness checks should only fail when the process must be restarted, e.g. a stuck loop.
+// All of them are critical
+func (h *Health) AddLivenessCheck(name string, fn CheckFunc) {
+	h.liveness = append(h.liveness, check{name, true, fn})
+}
+
+// Failing critical readiness checks make the service not ready,
+// failing non-critical ones only degrade the reported status
+func (h *Health) AddReadinessCheck(name string, critical bool, fn CheckFunc) {
+	h.readiness = append(h.readiness, check{name, critical, fn})
+}
+
+// Handler for /healthz
+func (h *Health) Liveness(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.liveness)
+}
+
+// Handler for /readyz
+func (h *Health) Readiness(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, h.readiness)
+}
+
+// Run the readiness checks, e.g. to report the status over other protocols than HTTP
+func (h *Health) Ready(ctx context.Context) Report {
+	return h.run(ctx, h.readiness)
+}
+
+func (h *Health) serve(w http.ResponseWriter, r *http.Request, checks []check) {
+	report := h.run(r.Context(), checks)
+
+	status := http.StatusOK
+	if report.Status == StatusFail {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("failed to write health report: %v", err)
+	}
+}
+
+// Run all checks concurrently and aggregate their statuses
+func (h *Health) run(ctx context.Context, checks []check) Report {
+	results := make(map[string]CheckResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := h.runCheck(ctx, c)
+			mu.Lock()
+			results[c.name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	status := StatusOk
+	for _, result := range results {
+		if result.Status == StatusFail {
+			status = StatusFail
+			break
+		}
+		if result.Status == StatusDegraded {
+			status = StatusDegraded
+		}
+	}
+
+	return Report{
+		Status:        status,
+		Timestamp:     time.Now().UTC(),
+		UptimeSeconds: int64(time.Since(h.startedAt).Seconds()),
+		Checks:        results,
+	}
+}
+
+func (h *Health) runCheck(ctx context.Context, c check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := c.fn(ctx)
+	result := CheckResult{
+		Status:     StatusOk,
+		Critical:   c.critical,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		Details:    details,
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Status = StatusDegraded
+		if c.critical {
+			result.Status = StatusFail
+		}
+	}
+	return result
+}
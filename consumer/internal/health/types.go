@@ -0,0 +1,39 @@
+package health
+
+import (
+	"context"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOk       Status = "ok"
+	StatusDegraded Status = "degraded" // a non-critical dependency is failing
+	StatusFail     Status = "fail"
+)
+
+// Function checking a single dependency.
+// Returned details are reported as is, e.g. latency, partition lag or data age
+type CheckFunc func(ctx context.Context) (map[string]any, error)
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+type CheckResult struct {
+	Status     Status         `json:"status"`
+	Critical   bool           `json:"critical"`
+	DurationMs float64        `json:"duration_ms"`
+	Details    map[string]any `json:"details,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status        Status                 `json:"status"`
+	Timestamp     time.Time              `json:"timestamp"`
+	UptimeSeconds int64                  `json:"uptime_seconds"`
+	Checks        map[string]CheckResult `json:"checks"`
+}
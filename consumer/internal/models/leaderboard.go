@@ -0,0 +1,28 @@
+package models
+
+// Kinds of keys ranked in the leaderboards
+const (
+	LeaderboardTokens = "tokens"
+	LeaderboardPairs  = "pairs"
+)
+
+// Stats the leaderboards are ranked by
+const (
+	RankByVolume  = "volume"
+	RankByTxCount = "tx_count"
+)
+
+// Token or pair key ranked by its stats in the window, the top one is ranked 1
+type LeaderboardEntry struct {
+	Rank int    `json:"rank"`
+	Key  string `json:"key"`
+	Stats
+}
+
+// Top tokens or pairs of a period window ranked by volume or tx count
+type Leaderboard struct {
+	Window  string             `json:"window"`
+	Type    string             `json:"type"`
+	By      string             `json:"by"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
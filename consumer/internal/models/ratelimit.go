@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Token bucket refilled at Rate tokens per second up to Burst tokens, every request takes a token
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// Outcome of taking a token from a bucket.
+// Reset is how long until the bucket is full again, RetryAfter how long until the next token when none is left
+type Allowance struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
@@ -1,6 +1,94 @@
 package models
 
+import "time"
+
+// Period windows the stats are aggregated in, from the shortest to the longest
+var Windows = []string{"5min", "1h", "24h"}
+
+// Length of every period window
+var WindowDurations = map[string]time.Duration{
+	"5min": 5 * time.Minute,
+	"1h":   time.Hour,
+	"24h":  24 * time.Hour,
+}
+
+// Volume is the combined volume of the swaps of a key. Tokens also have their flows,
+// which are left out of the stats of pairs.
+// Amounts are the volumes in the native units of the tokens of the key, keyed by token,
+// e.g. the ETH of the token ETH, or the ETH and BTC legs of the pair ETH-BTC
 type Stats struct {
 	Volume  float64 `json:"volume"`
 	TxCount int64   `json:"tx_count"`
+	*Flows
+	Amounts map[string]float64 `json:"amounts,omitempty"`
+}
+
+// Sides a token takes in a swap, it's sold in the swaps from it and bought in the swaps to it
+const (
+	SideSold   = "sold"
+	SideBought = "bought"
+)
+
+// Volume of a token sold and bought, the net flow is the bought volume less the sold one
+type Flows struct {
+	SoldVolume   float64 `json:"sold_volume"`
+	BoughtVolume float64 `json:"bought_volume"`
+	NetFlow      float64 `json:"net_flow"`
+}
+
+// Directions of the swaps of a pair "FROM-TO" the pair stats are read for
+const (
+	DirectionFromTo = "from_to"
+	DirectionToFrom = "to_from"
+	DirectionBoth   = "both"
+)
+
+// Stats of the swaps of a pair in both directions, summed in its undirected aggregate.
+// Swaps from the quote token to the base token buy the base, swaps from the base to the quote sell it
+type PairStats struct {
+	Base  string `json:"base"`
+	Quote string `json:"quote"`
+	Stats
+	Buy  Stats `json:"buy"`
+	Sell Stats `json:"sell"`
+}
+
+// Stats of a single bucket of a window, starting at the start time
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Stats
+}
+
+// Per-bucket stats of a token or pair key in a period window, the oldest bucket first
+type Series struct {
+	Key           string   `json:"key"`
+	Window        string   `json:"window"`
+	BucketSeconds int64    `json:"bucket_seconds"`
+	Buckets       []Bucket `json:"buckets"`
+}
+
+// Stats of a token or pair key in a time range, summed from the buckets overlapping the range.
+// From and To are the bounds of those buckets, so they may be wider than the requested range.
+// Partial is set when the buckets at the start of the range aren't retained anymore
+type RangeStats struct {
+	Key           string    `json:"key"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	BucketSeconds int64     `json:"bucket_seconds"`
+	Partial       bool      `json:"partial"`
+	Stats
+}
+
+// Freshly aggregated stats of a single token or pair key in a period window.
+// Seq orders the updates of all keys and increases by one with every update of any key.
+// PrevSeq is the seq of the previous update of the same key and window, 0 for the first one.
+// TraceParent is the W3C traceparent of the span that published the update, it's never sent to clients
+type StatsUpdate struct {
+	Seq         uint64 `json:"seq,omitempty"`
+	PrevSeq     uint64 `json:"prev_seq,omitempty"`
+	Key         string `json:"key"`
+	Window      string `json:"window"`
+	Stats       *Stats `json:"stats"`
+	TxHash      string `json:"tx_hash"` // hash of the swap that caused the update
+	TraceParent string `json:"-"`
 }
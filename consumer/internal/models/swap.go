@@ -11,3 +11,10 @@ type SwapEvent struct {
 	UsdValue   float64   `json:"usd_value"`
 	Timestamp  time.Time `json:"timestamp"`
 }
+
+// Contribution of a swap to the stats of a token or pair key
+type StatsDelta struct {
+	UsdValue float64
+	Side     string             // side of the swap a token key is on, empty for pairs
+	Amounts  map[string]float64 // amounts of the tokens of the key swapped, in their native units
+}
@@ -0,0 +1,11 @@
+package repositories
+
+import (
+	"consumer/internal/models"
+	"context"
+)
+
+// Shared token buckets, e.g. services.RedisAccessRepo
+type Limiter interface {
+	TakeToken(ctx context.Context, key string, limit models.RateLimit) (*models.Allowance, error)
+}
@@ -3,9 +3,19 @@ package repositories
 import (
 	"consumer/internal/models"
 	"context"
+	"time"
 )
 
 type StatsRepo interface {
 	GetStats(ctx context.Context, key string) (*models.Stats, error)
-	UpsertStats(ctx context.Context, key string, value float64) (map[string]*models.Stats, error)
+	GetStatsBatch(ctx context.Context, keys []string) (map[string]*models.Stats, map[string]error, error)
+	GetSeries(ctx context.Context, key string) (*models.Series, error)
+	GetRangeStats(ctx context.Context, key string, from, to time.Time) (*models.RangeStats, error)
+	GetLeaderboard(ctx context.Context, window, kind, by string, limit int) (*models.Leaderboard, error)
+	UpsertStats(ctx context.Context, key string, delta models.StatsDelta) (map[string]*models.Stats, error)
+	LastUpdate(ctx context.Context) (time.Time, error)
+	Ping(ctx context.Context) error
+	PublishUpdates(ctx context.Context, updates []models.StatsUpdate) error
+	ReadUpdates(ctx context.Context, afterID string, block time.Duration) ([]models.StatsUpdate, string, error)
+	RecentUpdates(ctx context.Context, count int64) ([]models.StatsUpdate, string, error)
 }
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"consumer/internal/rest/middleware"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// Respond with the body encoded as JSON along with its ETag, and with 304 when the client has it already.
+// Clients may cache the response for the max age, which is as long as the API caches the stats.
+// Responses to authenticated clients are private, so that shared caches don't serve them to others
+func respondCached(c *gin.Context, maxAge time.Duration, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.Error(errors.Wrapf(err, "failed to marshal response of %s", c.Request.URL.Path))
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	scope := "public"
+	if middleware.PrincipalOf(c) != nil {
+		scope = "private"
+	}
+	if seconds := int(maxAge.Seconds()); seconds > 0 {
+		c.Header("Cache-Control", scope+", max-age="+strconv.Itoa(seconds))
+	} else {
+		c.Header("Cache-Control", scope+", no-cache")
+	}
+
+	if matchesETag(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// Whether the If-None-Match header lists the ETag or is "*", weak ETags match their strong ones
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
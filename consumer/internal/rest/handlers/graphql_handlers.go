@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"consumer/internal/graph"
+	"consumer/internal/services"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type GraphQLHandler struct {
+	graph *graph.Graph
+	cfg   StreamConfig
+}
+
+func NewGraphQLHandler(g *graph.Graph, cfg StreamConfig) *GraphQLHandler {
+	return &GraphQLHandler{g, cfg}
+}
+
+func (h *GraphQLHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("/graphql", h.GraphQL)
+}
+
+// @Summary Query tokens, pairs and their stats with GraphQL
+// @Description Queries are answered with JSON. Subscriptions to the stats updates are streamed as Server-Sent Events
+// @Description when the request accepts text/event-stream, every response is a "next" event and the end is a "complete" event.
+// @Tags Stats
+// @Accept json
+// @Produce json,text/event-stream
+// @Param request body graph.Request true "GraphQL operation"
+// @Success 200 {object} map[string]interface{} "GraphQL response with data and errors"
+// @Failure 400 {object} middleware.Problem "Bad request"
+// @Router /graphql [post]
+func (h *GraphQLHandler) GraphQL(c *gin.Context) {
+	var req graph.Request
+	if err := c.ShouldBindJSON(&req); err != nil || req.Query == "" {
+		c.Error(services.NewError(services.ErrInvalidRequest, "invalid GraphQL request, expected a JSON body with a query"))
+		return
+	}
+
+	if !strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		c.JSON(http.StatusOK, h.graph.Exec(c.Request.Context(), req))
+		return
+	}
+	h.stream(c, req)
+}
+
+// Stream the responses in the distinct connections mode of the GraphQL over SSE protocol
+func (h *GraphQLHandler) stream(c *gin.Context, req graph.Request) {
+	ctx := c.Request.Context()
+	responses, err := h.graph.Subscribe(ctx, req)
+	if err != nil {
+		c.Error(errors.Wrap(err, "failed to subscribe"))
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Disable response buffering of nginx
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case response, ok := <-responses:
+			if !ok {
+				writeEvent(c.Writer, 0, "complete", nil)
+				return
+			}
+			data, err := json.Marshal(response)
+			if err != nil {
+				log.Printf("Error marshaling GraphQL response for %s: %v", c.ClientIP(), err)
+				return
+			}
+			if err := writeEvent(c.Writer, 0, "next", data); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"consumer/internal/models"
+	"consumer/internal/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// Entries returned when no limit is requested and the most that can be requested
+const (
+	defaultLeaderboardLimit = 10
+	maxLeaderboardLimit     = 100
+)
+
+type LeaderboardHandler struct {
+	service *services.StatsService
+}
+
+func NewLeaderboardHandler(s *services.StatsService) *LeaderboardHandler {
+	return &LeaderboardHandler{s}
+}
+
+func (h *LeaderboardHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/leaderboard/:window", h.GetLeaderboard)
+}
+
+// @Summary Get the top tokens or swap pairs in a specific period window
+// @Description Available period winows are "5min", "1h", "24h". Keys without swaps in the window aren't ranked.
+// @Tags Stats
+// @Accept json
+// @Produce json
+// @Param type query string false "Ranked keys, tokens or pairs" Enums(pairs, tokens) default(pairs)
+// @Param by query string false "Stats the keys are ranked by" Enums(volume, tx_count) default(volume)
+// @Param limit query int false "Number of top keys, at most 100" default(10)
+// @Success 200 {object} models.Leaderboard "Top keys with their stats"
+// @Failure 400 {object} middleware.Problem "Bad request"
+// @Failure 500 {object} middleware.Problem "Internal server error"
+// @Failure 503 {object} middleware.Problem "Stats store unavailable"
+// @Router /leaderboard/{window} [get]
+func (h *LeaderboardHandler) GetLeaderboard(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	window := c.Param("window")
+	isValidPeriod := services.IsValidPeriod(window)
+	if !isValidPeriod {
+		c.Error(services.NewError(services.ErrInvalidWindow, "invalid period window provided"))
+		return
+	}
+
+	kind := c.DefaultQuery("type", models.LeaderboardPairs)
+	if kind != models.LeaderboardPairs && kind != models.LeaderboardTokens {
+		c.Error(services.NewError(services.ErrInvalidRequest, "invalid type provided, expected pairs or tokens"))
+		return
+	}
+
+	by := c.DefaultQuery("by", models.RankByVolume)
+	if by != models.RankByVolume && by != models.RankByTxCount {
+		c.Error(services.NewError(services.ErrInvalidRequest, "invalid ranking provided, expected volume or tx_count"))
+		return
+	}
+
+	limit := defaultLeaderboardLimit
+	if param := c.Query("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 1 || n > maxLeaderboardLimit {
+			c.Error(services.NewError(services.ErrInvalidRequest, "invalid limit provided, expected 1 to %d", maxLeaderboardLimit))
+			return
+		}
+		limit = n
+	}
+
+	board, err := h.service.GetLeaderboard(ctx, window, kind, by, limit)
+	if err != nil {
+		c.Error(errors.Wrapf(err, "failed to get the %s leaderboard of window %s by %s from the stats service", kind, window, by))
+		return
+	}
+
+	respondCached(c, h.service.CacheTTL(window), board)
+}
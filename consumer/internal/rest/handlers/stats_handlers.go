@@ -1,11 +1,15 @@
 package handlers
 
 import (
-	"consumer/internal/rest/middleware"
+	"consumer/internal/models"
 	"consumer/internal/services"
+	"consumer/internal/utils"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -22,90 +26,337 @@ func NewStatsHandler(s *services.StatsService) *StatsHandler {
 func (h *StatsHandler) RegisterRoutes(r *gin.RouterGroup) {
 	r.GET("/stats/:window/tokens/:token", h.GetTokenStats)
 	r.GET("/stats/:window/pairs/:pair", h.GetPairStats)
+	r.GET("/stats/:window/tokens/:token/series", h.GetTokenSeries)
+	r.GET("/stats/:window/pairs/:pair/series", h.GetPairSeries)
+	r.POST("/stats/query", h.QueryStats)
 }
 
-type ErrorResponse struct {
-	Error string `json:"error" example:"error message"`
-}
+// Most keys times windows a single batch query can read
+const maxQueryItems = 100
 
 // @Summary Get single token stats in a specific period window
 // @Description Available period winows are "5min", "1h", "24h". Available tokens are "BTC", "USDT", "TON", "SOL", "ETH".
+// @Description When from or to is given the stats of the time range are returned as models.RangeStats, the window is its length when only one of them is.
 // @Tags Stats
 // @Accept json
 // @Produce json
+// @Param from query string false "Start of the time range, RFC3339 or unix time"
+// @Param to query string false "End of the time range, RFC3339 or unix time"
 // @Success 200 {array} models.Stats "Token stats"
-// @Success 400 {array} ErrorResponse "Bad request"
-// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 400 {object} middleware.Problem "Bad request"
+// @Failure 500 {object} middleware.Problem "Internal server error"
+// @Failure 503 {object} middleware.Problem "Stats store unavailable"
+// @Failure 404 {object} middleware.Problem "No swaps of the token in the window"
 // @Router /stats/{window}/tokens/{token} [get]
 func (h *StatsHandler) GetTokenStats(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	window := c.Param("window")
-	isValidPeriod := middleware.IsValidPeriod(window)
+	isValidPeriod := services.IsValidPeriod(window)
 	if !isValidPeriod {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period window provided"})
+		c.Error(services.NewError(services.ErrInvalidWindow, "invalid period window provided"))
 		return
 	}
 
 	token := c.Param("token")
-	isValidToken := middleware.IsValidToken(token)
+	isValidToken := services.IsValidToken(token)
 	if !isValidToken {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid token provided"})
+		c.Error(services.NewError(services.ErrInvalidToken, "invalid token provided"))
 		return
 	}
 
-	statsKey := fmt.Sprintf("stats:%s:%s", token, window)
-	stats, err := h.service.GetStats(ctx, statsKey)
-	if err != nil {
-		log.Println(errors.Wrapf(err, "failed to get stats from the stats service for the key %s", statsKey).Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	if c.Query("from") != "" || c.Query("to") != "" {
+		h.getRangeStats(c, strings.ToUpper(token), window)
 		return
 	}
-	if stats == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "no stats for the provided token and period window"})
+
+	statsKey := fmt.Sprintf("stats:%s:%s", strings.ToUpper(token), window)
+	stats, err := h.service.GetStats(ctx, statsKey)
+	if err != nil {
+		c.Error(errors.Wrapf(err, "failed to get stats from the stats service for the key %s", statsKey))
 		return
 	}
 
-	c.JSON(http.StatusOK, stats)
+	respondCached(c, h.service.CacheTTL(window), stats)
 }
 
 // @Summary Get swap pair stats in a specific period window
 // @Description Available period are "5min", "1h", "24h". Available tokens are "BTC", "USDT", "TON", "SOL", "ETH".
+// @Description When from or to is given the stats of the time range are returned as models.RangeStats, the window is its length when only one of them is.
+// @Description Swaps from the first to the second token of the pair are read by default. Both directions are read as models.PairStats,
+// @Description with the first token as the base that the swaps of the to_from direction buy and the swaps of the from_to direction sell.
+// @Description The time range of both directions is read from their undirected aggregate, without the breakdown.
 // @Tags Stats
 // @Accept json
 // @Produce json
+// @Param direction query string false "Direction of the swaps" Enums(from_to, to_from, both) default(from_to)
+// @Param from query string false "Start of the time range, RFC3339 or unix time"
+// @Param to query string false "End of the time range, RFC3339 or unix time"
 // @Success 200 {array} models.Stats "Swap pair stats"
-// @Success 400 {array} ErrorResponse "Bad request"
-// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 400 {object} middleware.Problem "Bad request"
+// @Failure 500 {object} middleware.Problem "Internal server error"
+// @Failure 503 {object} middleware.Problem "Stats store unavailable"
+// @Failure 404 {object} middleware.Problem "No swaps of the pair in the window"
 // @Router /stats/{window}/pairs/{pair} [get]
 func (h *StatsHandler) GetPairStats(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	window := c.Param("window")
-	isValidPeriod := middleware.IsValidPeriod(window)
+	isValidPeriod := services.IsValidPeriod(window)
 	if !isValidPeriod {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period window provided"})
+		c.Error(services.NewError(services.ErrInvalidWindow, "invalid period window provided"))
 		return
 	}
 
 	pair := c.Param("pair")
-	isValidPair := middleware.IsValidPair(pair)
+	isValidPair := services.IsValidPair(pair)
 	if !isValidPair {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pair provided"})
+		c.Error(services.NewError(services.ErrInvalidPair, "invalid pair provided"))
 		return
 	}
 
-	statsKey := fmt.Sprintf("stats:%s:%s", pair, window)
+	tokens := strings.Split(strings.ToUpper(pair), "-")
+	var key string
+	direction := c.DefaultQuery("direction", models.DirectionFromTo)
+	switch direction {
+	case models.DirectionFromTo:
+		key = utils.BuildHyphenKey(tokens[0], tokens[1])
+	case models.DirectionToFrom:
+		key = utils.BuildHyphenKey(tokens[1], tokens[0])
+	case models.DirectionBoth:
+		key = utils.BuildUndirectedKey(tokens[0], tokens[1])
+	default:
+		c.Error(services.NewError(services.ErrInvalidRequest, "invalid direction provided, expected from_to, to_from or both"))
+		return
+	}
+
+	if c.Query("from") != "" || c.Query("to") != "" {
+		h.getRangeStats(c, key, window)
+		return
+	}
+
+	if direction == models.DirectionBoth {
+		stats, err := h.service.GetPairStats(ctx, tokens[0], tokens[1], window)
+		if err != nil {
+			c.Error(errors.Wrapf(err, "failed to get stats from the stats service for both directions of the pair %s", pair))
+			return
+		}
+		respondCached(c, h.service.CacheTTL(window), stats)
+		return
+	}
+
+	statsKey := fmt.Sprintf("stats:%s:%s", key, window)
 	stats, err := h.service.GetStats(ctx, statsKey)
 	if err != nil {
-		log.Println(errors.Wrapf(err, "failed to get stats from the stats service for the key %s", statsKey).Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		c.Error(errors.Wrapf(err, "failed to get stats from the stats service for the key %s", statsKey))
+		return
+	}
+
+	respondCached(c, h.service.CacheTTL(window), stats)
+}
+
+// Respond with the stats of the key in the time range of the from and to query params.
+// When only one of them is given the range is as long as the window
+func (h *StatsHandler) getRangeStats(c *gin.Context, key, window string) {
+	ctx := c.Request.Context()
+
+	from, to, err := parseRange(c.Query("from"), c.Query("to"), models.WindowDurations[window])
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	stats, err := h.service.GetRangeStats(ctx, key, from, to)
+	if err != nil {
+		c.Error(errors.Wrapf(err, "failed to get stats from the stats service for the key %s from %v to %v", key, from, to))
+		return
+	}
+
+	respondCached(c, h.service.CacheTTL(window), stats)
+}
+
+// Parse the bounds of a time range, the missing one is the length away from the other
+func parseRange(fromParam, toParam string, length time.Duration) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if fromParam != "" {
+		if from, err = parseTime(fromParam); err != nil {
+			return from, to, services.NewError(services.ErrInvalidRequest, "invalid from provided, expected an RFC3339 or unix time")
+		}
+	}
+	if toParam != "" {
+		if to, err = parseTime(toParam); err != nil {
+			return from, to, services.NewError(services.ErrInvalidRequest, "invalid to provided, expected an RFC3339 or unix time")
+		}
+	}
+
+	switch {
+	case fromParam == "":
+		from = to.Add(-length)
+	case toParam == "":
+		to = from.Add(length)
+	}
+	if !from.Before(to) {
+		return from, to, services.NewError(services.ErrInvalidRequest, "invalid time range provided, from must be before to")
+	}
+	return from, to, nil
+}
+
+// Parse an RFC3339 time like "2025-01-01T12:00:00Z" or a unix time in seconds
+func parseTime(param string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(param, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, param)
+}
+
+// @Summary Get per-bucket token stats in a specific period window
+// @Description Buckets are 1 minute in the "5min" window, 5 minutes in "1h" and 1 hour in "24h", the oldest first.
+// @Description The last bucket is the current one and is still filling up. Buckets without swaps have zero stats.
+// @Tags Stats
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.Series "Token stats per bucket"
+// @Failure 400 {object} middleware.Problem "Bad request"
+// @Failure 500 {object} middleware.Problem "Internal server error"
+// @Failure 503 {object} middleware.Problem "Stats store unavailable"
+// @Router /stats/{window}/tokens/{token}/series [get]
+func (h *StatsHandler) GetTokenSeries(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	window := c.Param("window")
+	isValidPeriod := services.IsValidPeriod(window)
+	if !isValidPeriod {
+		c.Error(services.NewError(services.ErrInvalidWindow, "invalid period window provided"))
+		return
+	}
+
+	token := c.Param("token")
+	isValidToken := services.IsValidToken(token)
+	if !isValidToken {
+		c.Error(services.NewError(services.ErrInvalidToken, "invalid token provided"))
+		return
+	}
+
+	statsKey := fmt.Sprintf("stats:%s:%s", strings.ToUpper(token), window)
+	series, err := h.service.GetSeries(ctx, statsKey)
+	if err != nil {
+		c.Error(errors.Wrapf(err, "failed to get series from the stats service for the key %s", statsKey))
+		return
+	}
+
+	respondCached(c, h.service.CacheTTL(window), series)
+}
+
+// @Summary Get per-bucket swap pair stats in a specific period window
+// @Description Buckets are 1 minute in the "5min" window, 5 minutes in "1h" and 1 hour in "24h", the oldest first.
+// @Description The last bucket is the current one and is still filling up. Buckets without swaps have zero stats.
+// @Tags Stats
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.Series "Swap pair stats per bucket"
+// @Failure 400 {object} middleware.Problem "Bad request"
+// @Failure 500 {object} middleware.Problem "Internal server error"
+// @Failure 503 {object} middleware.Problem "Stats store unavailable"
+// @Router /stats/{window}/pairs/{pair}/series [get]
+func (h *StatsHandler) GetPairSeries(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	window := c.Param("window")
+	isValidPeriod := services.IsValidPeriod(window)
+	if !isValidPeriod {
+		c.Error(services.NewError(services.ErrInvalidWindow, "invalid period window provided"))
+		return
+	}
+
+	pair := c.Param("pair")
+	isValidPair := services.IsValidPair(pair)
+	if !isValidPair {
+		c.Error(services.NewError(services.ErrInvalidPair, "invalid pair provided"))
+		return
+	}
+
+	statsKey := fmt.Sprintf("stats:%s:%s", strings.ToUpper(pair), window)
+	series, err := h.service.GetSeries(ctx, statsKey)
+	if err != nil {
+		c.Error(errors.Wrapf(err, "failed to get series from the stats service for the key %s", statsKey))
 		return
 	}
-	if stats == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "no stats for the provided pair and period window"})
+
+	respondCached(c, h.service.CacheTTL(window), series)
+}
+
+// @Summary Get the stats of many tokens and pairs in many period windows at once
+// @Description Every key is read in every window, all windows when none are given. Invalid keys or windows
+// @Description and failed reads are reported per item, so the rest of the results are still returned.
+// @Tags Stats
+// @Accept json
+// @Produce json
+// @Param query body StatsQueryRequest true "Keys and windows"
+// @Success 200 {object} StatsQueryResponse "Stats keyed by key and window"
+// @Failure 400 {object} middleware.Problem "Bad request"
+// @Failure 500 {object} middleware.Problem "Internal server error"
+// @Failure 503 {object} middleware.Problem "Stats store unavailable"
+// @Router /stats/query [post]
+func (h *StatsHandler) QueryStats(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req StatsQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(services.NewError(services.ErrInvalidRequest, "invalid request body"))
 		return
 	}
+	if len(req.Keys) == 0 {
+		c.Error(services.NewError(services.ErrInvalidRequest, "no keys provided"))
+		return
+	}
+	windows := req.Windows
+	if len(windows) == 0 {
+		windows = models.Windows
+	}
+	if len(req.Keys)*len(windows) > maxQueryItems {
+		c.Error(services.NewError(services.ErrInvalidRequest, "too many keys and windows, at most %d combinations are allowed", maxQueryItems))
+		return
+	}
+
+	type keyWindow struct{ key, window string }
+	results := make(map[string]map[string]StatsQueryItem, len(req.Keys))
+	statsKeys := make(map[string]keyWindow) // stats key like "stats:ETH:5min" to its key and window
+	for _, key := range req.Keys {
+		key = strings.ToUpper(key)
+		if results[key] == nil {
+			results[key] = make(map[string]StatsQueryItem, len(windows))
+		}
+		validKey := services.IsValidToken(key) || services.IsValidPair(key)
+		for _, window := range windows {
+			switch {
+			case !services.IsValidPeriod(window):
+				results[key][window] = StatsQueryItem{Error: "invalid period window provided"}
+			case !validKey:
+				results[key][window] = StatsQueryItem{Error: "invalid token or pair provided"}
+			default:
+				statsKeys[fmt.Sprintf("stats:%s:%s", key, window)] = keyWindow{key, window}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(statsKeys))
+	for statsKey := range statsKeys {
+		keys = append(keys, statsKey)
+	}
+	stats, keyErrs, err := h.service.GetStatsBatch(ctx, keys)
+	if err != nil {
+		c.Error(errors.Wrapf(err, "failed to get stats from the stats service for %d keys", len(keys)))
+		return
+	}
+	for statsKey, kw := range statsKeys {
+		if err, ok := keyErrs[statsKey]; ok {
+			log.Println(errors.Wrapf(err, "failed to get stats from the stats service for the key %s", statsKey).Error())
+			results[kw.key][kw.window] = StatsQueryItem{Error: "failed to read stats"}
+			continue
+		}
+		results[kw.key][kw.window] = StatsQueryItem{Stats: stats[statsKey]}
+	}
 
-	c.JSON(http.StatusOK, stats)
+	c.JSON(http.StatusOK, StatsQueryResponse{Results: results})
 }
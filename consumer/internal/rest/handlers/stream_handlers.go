@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"consumer/internal/feed"
+	"consumer/internal/services"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type StreamHandler struct {
+	hub   *feed.Hub
+	stats feed.StatsReader
+	cfg   StreamConfig
+}
+
+func NewStreamHandler(hub *feed.Hub, stats feed.StatsReader, cfg StreamConfig) *StreamHandler {
+	return &StreamHandler{hub, stats, cfg}
+}
+
+func (h *StreamHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/stream", h.Stream)
+}
+
+// @Summary Stream stats updates as Server-Sent Events
+// @Description Every event carries the same JSON envelope as the WebSocket messages, the event name is its type ("snapshot", "update", "alert").
+// @Description Update ids are their seq, so a reconnecting EventSource resumes with the Last-Event-ID header.
+// @Description Keys are tokens, pairs or patterns with "*" for any token, all keys are streamed when omitted.
+// @Tags Stats
+// @Produce text/event-stream
+// @Param keys query string false "Comma-separated keys, e.g. ETH,BTC-*"
+// @Param windows query string false "Comma-separated period windows, e.g. 5min,1h"
+// @Param last_event_id query int false "Seq to resume after, for clients that can't set the Last-Event-ID header"
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} middleware.Problem "Bad request"
+// @Router /stream [get]
+func (h *StreamHandler) Stream(c *gin.Context) {
+	keys := feed.AllKeys
+	if param := c.Query("keys"); param != "" {
+		keys = strings.Split(param, ",")
+	}
+	var windows []string
+	if param := c.Query("windows"); param != "" {
+		windows = strings.Split(param, ",")
+	}
+	subs, err := feed.BuildSubscriptions(keys, windows)
+	if err != nil {
+		c.Error(services.NewError(services.ErrInvalidRequest, "%s", err.Error()))
+		return
+	}
+
+	var lastEventID *uint64
+	param := c.GetHeader("Last-Event-ID")
+	if param == "" {
+		param = c.Query("last_event_id")
+	}
+	if param != "" {
+		seq, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			c.Error(services.NewError(services.ErrInvalidRequest, "invalid last event id"))
+			return
+		}
+		lastEventID = &seq
+	}
+
+	listener := h.hub.Subscribe(subs, h.cfg.QueueSize)
+	defer listener.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Disable response buffering of nginx
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	seq, err := h.writeInitial(ctx, c.Writer, subs, lastEventID)
+	if err != nil {
+		log.Printf("Error starting stream for %s: %v", c.ClientIP(), err)
+		return
+	}
+
+	heartbeat := time.NewTicker(h.cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-listener.Overflow():
+			log.Printf("Closing slow stream of %s: queue of %d updates is full", c.ClientIP(), listener.QueueSize())
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case entry := <-listener.Updates():
+			if entry.Update.Seq <= seq {
+				continue
+			}
+			if err := writeEvent(c.Writer, entry.Update.Seq, feed.TypeUpdate, entry.Message); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Write the missed updates when resuming from the last event id is possible, the snapshot otherwise.
+// Returns the seq of the last update covered by them
+func (h *StreamHandler) writeInitial(ctx context.Context, w gin.ResponseWriter, subs []feed.Subscription, lastEventID *uint64) (uint64, error) {
+	initial, err := feed.LoadInitial(ctx, h.hub, h.stats, subs, lastEventID)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range initial.Replay {
+		if err := writeEvent(w, entry.Update.Seq, feed.TypeUpdate, entry.Message); err != nil {
+			return 0, err
+		}
+	}
+	if initial.Resumed {
+		// Resume from the last update even if none of the replayed ones matched
+		return initial.Seq, writeID(w, initial.Seq)
+	}
+	for _, message := range initial.Messages {
+		// Snapshots carry the seq as their id, alerts have none
+		id := initial.Seq
+		if message.Type != feed.TypeSnapshot {
+			id = 0
+		}
+		if err := writeMessage(w, id, message); err != nil {
+			return 0, err
+		}
+	}
+	return initial.Seq, nil
+}
+
+func writeMessage(w gin.ResponseWriter, id uint64, message feed.Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal %s message", message.Type)
+	}
+	return writeEvent(w, id, message.Type, data)
+}
+
+// Write a single event and flush it to the client, ids of 0 are omitted
+func writeEvent(w gin.ResponseWriter, id uint64, event string, data []byte) error {
+	var b strings.Builder
+	if id != 0 {
+		fmt.Fprintf(&b, "id: %d\n", id)
+	}
+	fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", event, data)
+	if _, err := w.WriteString(b.String()); err != nil {
+		return errors.Wrap(err, "failed to write event")
+	}
+	w.Flush()
+	return nil
+}
+
+// Move the last event id of the client forward without an event
+func writeID(w gin.ResponseWriter, id uint64) error {
+	if id == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "id: %d\n\n", id); err != nil {
+		return errors.Wrap(err, "failed to write event id")
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"consumer/internal/models"
+	"time"
+)
+
+type StreamConfig struct {
+	HeartbeatInterval time.Duration // how often a comment is sent to keep idle streams open through proxies
+	QueueSize         int           // updates buffered per stream, the stream is closed when its queue overflows
+}
+
+// Keys are tokens "ETH" or pairs "BTC-USDT", every key is read in every window
+type StatsQueryRequest struct {
+	Keys    []string `json:"keys" example:"ETH,BTC-USDT"`
+	Windows []string `json:"windows,omitempty" example:"5min,1h"` // all period windows when omitted
+}
+
+// Stats of a key in a window, or the error reading them
+type StatsQueryItem struct {
+	Stats *models.Stats `json:"stats,omitempty"`
+	Error string        `json:"error,omitempty"`
+}
+
+// Results keyed by key and then by window, e.g. results["ETH"]["5min"]
+type StatsQueryResponse struct {
+	Results map[string]map[string]StatsQueryItem `json:"results"`
+}
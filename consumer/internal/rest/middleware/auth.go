@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"consumer/internal/auth"
+	"consumer/internal/services"
+	"log"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// Context key of the authenticated principal
+const principalKey = "principal"
+
+// Reject the requests without valid credentials, the principal of the others is kept in the context.
+// Failures to look up the credentials, e.g. in the key store, are reported as ErrStoreUnavailable.
+// Credentials in the query are accepted on the given routes only, i.e. the ones browsers can't send headers to,
+// since URLs end up in logs and browser histories
+func Authenticate(a auth.Authenticator, queryRoutes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := c.Request
+		if !slices.Contains(queryRoutes, c.FullPath()) {
+			r = auth.WithoutQueryCredentials(r)
+		}
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			if errors.Is(err, auth.ErrNoCredentials) || errors.Is(err, auth.ErrInvalidCredentials) {
+				log.Printf("Rejecting client %s: %v", c.ClientIP(), err)
+			} else {
+				err = &services.Error{Kind: services.ErrStoreUnavailable, Detail: "authentication is unavailable", Err: err}
+			}
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// Principal authenticated by Authenticate, nil when authentication is disabled
+func PrincipalOf(c *gin.Context) *auth.Principal {
+	value, _ := c.Get(principalKey)
+	principal, _ := value.(*auth.Principal)
+	return principal
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"consumer/internal/auth"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Access log like gin.Logger, with the credentials in the query redacted
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		if p.Latency > time.Minute {
+			p.Latency = p.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			p.StatusCode,
+			p.Latency,
+			p.ClientIP,
+			p.Method,
+			redactQuery(p.Path),
+			p.ErrorMessage,
+		)
+	})
+}
+
+// Path with the values of the credential parameters of its query replaced
+func redactQuery(path string) string {
+	path, rawQuery, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	// Parse errors are ignored, the parameters parsed until then are still redacted
+	query, _ := url.ParseQuery(rawQuery)
+	if !auth.HasQueryCredentials(query) {
+		return path + "?" + rawQuery
+	}
+	for _, param := range auth.QueryParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+		}
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"consumer/internal/auth"
+	"consumer/internal/services"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// Problem details of a failed request as defined by RFC 7807, served as application/problem+json.
+// Code identifies the kind of the problem and never changes, unlike the human-readable title and detail
+type Problem struct {
+	Type      string `json:"type" example:"about:blank"`
+	Title     string `json:"title" example:"Not Found"`
+	Status    int    `json:"status" example:"404"`
+	Detail    string `json:"detail,omitempty" example:"no swaps of ETH in the 5min window"`
+	Instance  string `json:"instance,omitempty" example:"/api/v1/stats/5min/tokens/ETH"`
+	Code      string `json:"code" example:"not_found"`
+	RequestID string `json:"request_id" example:"5f0c6d2e8a3b4c1d9e7f6a5b4c3d2e1f"`
+}
+
+// Status and code of every kind of error, errors of other kinds are internal server errors
+var problemKinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{services.ErrNotFound, http.StatusNotFound, "not_found"},
+	{services.ErrInvalidWindow, http.StatusBadRequest, "invalid_window"},
+	{services.ErrInvalidToken, http.StatusBadRequest, "invalid_token"},
+	{services.ErrInvalidPair, http.StatusBadRequest, "invalid_pair"},
+	{services.ErrInvalidRequest, http.StatusBadRequest, "invalid_request"},
+	{services.ErrStoreUnavailable, http.StatusServiceUnavailable, "store_unavailable"},
+	{auth.ErrNoCredentials, http.StatusUnauthorized, "missing_credentials"},
+	{auth.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{ErrRateLimited, http.StatusTooManyRequests, "rate_limited"},
+}
+
+// Respond with the problem details of the last error the handlers added to the context with c.Error,
+// unless they have responded already. Server errors are logged along with the request ID
+func Problems() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+
+		problem := Problem{
+			Type:      "about:blank",
+			Status:    http.StatusInternalServerError,
+			Instance:  c.Request.URL.Path,
+			Code:      "internal",
+			RequestID: RequestIDOf(c),
+		}
+		for _, kind := range problemKinds {
+			if errors.Is(err, kind.kind) {
+				problem.Status, problem.Code = kind.status, kind.code
+				problem.Detail = kind.kind.Error()
+				break
+			}
+		}
+		var domainErr *services.Error
+		if errors.As(err, &domainErr) {
+			problem.Detail = domainErr.Detail
+		}
+		problem.Title = http.StatusText(problem.Status)
+
+		if problem.Status >= http.StatusInternalServerError {
+			log.Printf("Request %s to %s failed: %v", problem.RequestID, c.Request.URL.Path, err)
+		}
+		if problem.Status == http.StatusUnauthorized {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+		}
+		body, _ := json.Marshal(problem)
+		c.Data(problem.Status, "application/problem+json", body)
+	}
+}
+
+// Code of the problem the error is reported as, for the APIs reporting errors in their own format
+func ProblemCode(err error) string {
+	for _, kind := range problemKinds {
+		if errors.Is(err, kind.kind) {
+			return kind.code
+		}
+	}
+	return "internal"
+}
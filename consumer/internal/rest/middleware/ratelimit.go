@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"consumer/internal/models"
+	"consumer/internal/repositories"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limit the requests of every client IP
+func RateLimitByIP(l repositories.Limiter, limit models.RateLimit) gin.HandlerFunc {
+	return rateLimit(l, limit, func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	})
+}
+
+// Limit the requests of every authenticated client, must follow Authenticate
+func RateLimitByKey(l repositories.Limiter, limit models.RateLimit) gin.HandlerFunc {
+	return rateLimit(l, limit, func(c *gin.Context) string {
+		if principal := PrincipalOf(c); principal != nil {
+			return "key:" + principal.String()
+		}
+		return ""
+	})
+}
+
+// Take a token from the bucket of the request and reject it with ErrRateLimited when the bucket is empty.
+// The RateLimit headers report the limit of the bucket, the tokens left and the seconds until it's full again.
+// Requests are let through when the buckets can't be read, so that an outage of the store doesn't take the API down
+func rateLimit(l repositories.Limiter, limit models.RateLimit, bucketOf func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bucket := bucketOf(c)
+		if bucket == "" {
+			c.Next()
+			return
+		}
+
+		allowance, err := l.TakeToken(c.Request.Context(), bucket, limit)
+		if err != nil {
+			log.Printf("Error rate limiting %s, letting the request through: %v", bucket, err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(allowance.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(int(allowance.Reset.Seconds())))
+		if !allowance.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(allowance.RetryAfter.Seconds())))
+			c.Error(ErrRateLimited)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header the request ID is read from and returned in, so that it can be set by a proxy in front of the API
+const RequestIDHeader = "X-Request-ID"
+
+// Context key of the request ID
+const requestIDKey = "request_id"
+
+// IDs set by clients or proxies are kept when they are reasonably short and safe to log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// Identify every request with the ID from the X-Request-ID header or a random one, returned in the same header
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// ID of the request set by RequestID
+func RequestIDOf(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,7 +1,14 @@
 package rest
 
 import (
+	"consumer/internal/auth"
+	"consumer/internal/feed"
+	"consumer/internal/graph"
+	"consumer/internal/health"
+	"consumer/internal/models"
+	"consumer/internal/repositories"
 	"consumer/internal/rest/handlers"
+	"consumer/internal/rest/middleware"
 	"consumer/internal/services"
 	"fmt"
 	"log"
@@ -10,6 +17,7 @@ import (
 	"github.com/pkg/errors"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	// _ "blog-posts-api/docs"
 )
 
@@ -24,23 +32,57 @@ import (
 // @tag.name Stats
 // @tag.description Operations related to stats
 
+// Authentication and rate limiting of the /api/v1 endpoints.
+// Every client IP is limited before authentication, so that guessing keys is limited too,
+// and every authenticated client is limited after it. A limit with zero rate is disabled.
+// The client IP is taken from X-Forwarded-For only when the request comes from one of the trusted proxies
+type AccessConfig struct {
+	Authenticator  auth.Authenticator   // authentication is disabled when nil
+	Limiter        repositories.Limiter // rate limiting is disabled when nil
+	KeyLimit       models.RateLimit
+	IPLimit        models.RateLimit
+	TrustedProxies []string // IPs or CIDRs, the remote address is the client IP when empty
+}
+
 type RestApi struct {
 	port         string
 	statsService *services.StatsService
+	health       *health.Health
+	hub          *feed.Hub
+	streamCfg    handlers.StreamConfig
+	graphCfg     graph.Config
+	access       AccessConfig
 }
 
-func New(port string, statsService *services.StatsService) *RestApi {
-	return &RestApi{port, statsService}
+func New(port string, statsService *services.StatsService, health *health.Health, hub *feed.Hub, streamCfg handlers.StreamConfig, graphCfg graph.Config, access AccessConfig) *RestApi {
+	return &RestApi{port, statsService, health, hub, streamCfg, graphCfg, access}
 }
 
 func (s *RestApi) Run() error {
-	r := gin.Default()
+	// The access log of gin.Default would write the credentials sent in the query
+	r := gin.New()
+	r.Use(middleware.Logger(), gin.Recovery())
+
+	// Without trusted proxies anyone could pick their IP, and so their rate limit bucket, with X-Forwarded-For
+	if err := r.SetTrustedProxies(s.access.TrustedProxies); err != nil {
+		return errors.Wrapf(err, "invalid trusted proxies")
+	}
+
+	// Server span per request, Redis reads of the handlers become its children
+	r.Use(otelgin.Middleware("api"))
+
+	// Errors of the handlers and middlewares below are responded with problem details carrying the request ID
+	r.Use(middleware.RequestID(), middleware.Problems())
+	r.NoRoute(func(c *gin.Context) {
+		c.Error(services.NewError(services.ErrNotFound, "no endpoint %s %s", c.Request.Method, c.Request.URL.Path))
+	})
 
 	// Add CORS middleware for Swagger UI
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, If-None-Match, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "ETag, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After, X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -52,15 +94,33 @@ func (s *RestApi) Run() error {
 	// Swagger documentation route
 	r.GET("/api/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// Health check endpoint
-	r.GET("/api/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Kubernetes probes, /api/health is kept for backward compatibility
+	r.GET("/healthz", gin.WrapF(s.health.Liveness))
+	r.GET("/readyz", gin.WrapF(s.health.Readiness))
+	r.GET("/api/health", gin.WrapF(s.health.Readiness))
 
 	handler := handlers.NewStatsHandler(s.statsService)
+	leaderboardHandler := handlers.NewLeaderboardHandler(s.statsService)
+	streamHandler := handlers.NewStreamHandler(s.hub, s.statsService.Uncached(), s.streamCfg)
+	graphQLHandler := handlers.NewGraphQLHandler(graph.New(s.statsService, s.hub, s.graphCfg), s.streamCfg)
 	v1 := r.Group("/api/v1")
+	if s.access.Limiter != nil && s.access.IPLimit.Rate > 0 {
+		v1.Use(middleware.RateLimitByIP(s.access.Limiter, s.access.IPLimit))
+	}
+	if s.access.Authenticator != nil {
+		// EventSource can't set headers, so the stream accepts the credentials in the query too
+		v1.Use(middleware.Authenticate(s.access.Authenticator, "/api/v1/stream"))
+	} else {
+		log.Println("REST API authentication is disabled, anyone can read the stats")
+	}
+	if s.access.Limiter != nil && s.access.KeyLimit.Rate > 0 {
+		v1.Use(middleware.RateLimitByKey(s.access.Limiter, s.access.KeyLimit))
+	}
 	{
 		handler.RegisterRoutes(v1)
+		leaderboardHandler.RegisterRoutes(v1)
+		streamHandler.RegisterRoutes(v1)
+		graphQLHandler.RegisterRoutes(v1)
 	}
 
 	r.GET("/", func(c *gin.Context) {
@@ -69,16 +129,24 @@ func (s *RestApi) Run() error {
 			"version":  "1.0.0",
 			"docs":     "/api/docs/index.html",
 			"health":   "/api/health",
+			"liveness": "/healthz",
+			"ready":    "/readyz",
 			"api_base": "/api/v1",
 			"endpoints": map[string]string{
-				"GET /api/v1/stats/:window/tokens/:token": "Get single token stats in a specific period window",
-				"GET /api/v1/stats/:window/pairs/:pair":   "Get swap pair stats in a specific period window",
+				"GET /api/v1/stats/:window/tokens/:token":        "Get single token stats in a specific period window",
+				"GET /api/v1/stats/:window/pairs/:pair":          "Get swap pair stats in a specific period window",
+				"GET /api/v1/stats/:window/tokens/:token/series": "Get per-bucket token stats in a specific period window",
+				"GET /api/v1/stats/:window/pairs/:pair/series":   "Get per-bucket swap pair stats in a specific period window",
+				"POST /api/v1/stats/query":                       "Get stats of many tokens and pairs in many period windows at once",
+				"GET /api/v1/leaderboard/:window":                "Get the top tokens or swap pairs in a specific period window",
+				"GET /api/v1/stream":                             "Stream stats updates as Server-Sent Events",
+				"POST /api/v1/graphql":                           "Query tokens, pairs and their stats, or subscribe to the stats updates, with GraphQL",
 			},
 		})
 	})
 
 	log.Println("Stats API is starting...")
-	log.Printf("Health check available at: http://localhost:%s/api/health\n", s.port)
+	log.Printf("Health checks available at: http://localhost:%s/healthz and http://localhost:%s/readyz\n", s.port, s.port)
 	log.Printf("API endpoints available at: http://localhost:%s/api/v1\n", s.port)
 	log.Printf("Swagger docs available at: http://localhost:%s/api/docs/index.html\n", s.port)
 
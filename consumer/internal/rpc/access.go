@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"consumer/internal/auth"
+	"consumer/internal/models"
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Health checks are left open, so that the probes don't need credentials
+const healthServicePrefix = "/grpc.health.v1.Health/"
+
+// Interceptors limiting the client IP, authenticating the client and limiting its key, like the REST API does
+type access struct {
+	cfg AccessConfig
+}
+
+func (a *access) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := a.check(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Streams are checked once when they are opened
+func (a *access) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.check(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (a *access) check(ctx context.Context, method string) error {
+	if strings.HasPrefix(method, healthServicePrefix) {
+		return nil
+	}
+
+	if a.cfg.Limiter != nil && a.cfg.IPLimit.Rate > 0 {
+		if err := a.take(ctx, "ip:"+peerIP(ctx), a.cfg.IPLimit); err != nil {
+			return err
+		}
+	}
+
+	if a.cfg.Authenticator == nil {
+		return nil
+	}
+	principal, err := a.cfg.Authenticator.Authenticate(requestOf(ctx))
+	if err != nil {
+		if errors.Is(err, auth.ErrNoCredentials) || errors.Is(err, auth.ErrInvalidCredentials) {
+			log.Printf("Rejecting gRPC client %s: %v", peerIP(ctx), err)
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+		log.Printf("Error authenticating gRPC client %s: %v", peerIP(ctx), err)
+		return status.Error(codes.Unavailable, "authentication is unavailable")
+	}
+
+	if a.cfg.Limiter != nil && a.cfg.KeyLimit.Rate > 0 {
+		return a.take(ctx, "key:"+principal.String(), a.cfg.KeyLimit)
+	}
+	return nil
+}
+
+// Take a token from the bucket, calls are let through when the buckets can't be read like the requests of the REST API
+func (a *access) take(ctx context.Context, bucket string, limit models.RateLimit) error {
+	allowance, err := a.cfg.Limiter.TakeToken(ctx, bucket, limit)
+	if err != nil {
+		log.Printf("Error rate limiting %s, letting the call through: %v", bucket, err)
+		return nil
+	}
+	if !allowance.Allowed {
+		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %d seconds", int(allowance.RetryAfter.Seconds()))
+	}
+	return nil
+}
+
+// Request carrying the credentials of the call metadata, so that the authenticators of the REST API read them.
+// Only the headers are taken, credentials in query parameters don't exist in gRPC
+func requestOf(ctx context.Context) *http.Request {
+	r := (&http.Request{Header: make(http.Header), URL: &url.URL{}}).WithContext(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, name := range []string{auth.APIKeyHeader, "Authorization"} {
+		for _, value := range md.Get(name) {
+			r.Header.Add(name, value)
+		}
+	}
+	return r
+}
+
+// IP of the client making the call
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
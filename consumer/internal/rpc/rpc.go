@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"consumer/internal/feed"
+	"consumer/internal/health"
+	"consumer/internal/services"
+	statsv1 "consumer/proto/stats/v1"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// gRPC counterpart of the REST API for internal services, with the health and reflection services
+type RpcApi struct {
+	port         string
+	statsService *services.StatsService
+	health       *health.Health
+	hub          *feed.Hub
+	cfg          Config
+	access       AccessConfig
+}
+
+func New(port string, statsService *services.StatsService, health *health.Health, hub *feed.Hub, cfg Config, access AccessConfig) *RpcApi {
+	return &RpcApi{port, statsService, health, hub, cfg, access}
+}
+
+func (s *RpcApi) Run() error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", s.port))
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on port %s", s.port)
+	}
+
+	if s.access.Authenticator == nil {
+		log.Println("gRPC API authentication is disabled, anyone can read the stats")
+	}
+
+	// Server span per call, Redis reads of the calls become its children.
+	// Calls are authenticated and rate limited before they reach the services
+	interceptors := &access{s.access}
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors.unary),
+		grpc.ChainStreamInterceptor(interceptors.stream),
+	)
+	statsv1.RegisterStatsServiceServer(server, &statsServer{
+		stats:     s.statsService,
+		snapshots: s.statsService.Uncached(),
+		hub:       s.hub,
+		cfg:       s.cfg,
+	})
+
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go s.watchHealth(healthServer)
+
+	reflection.Register(server)
+
+	log.Printf("gRPC API available at: localhost:%s\n", s.port)
+	if err := server.Serve(lis); err != nil {
+		return errors.Wrapf(err, "failed to start grpc server")
+	}
+	return nil
+}
+
+// Report the outcome of the readiness checks as the status of the server and the stats service
+func (s *RpcApi) watchHealth(server *grpchealth.Server) {
+	ticker := time.NewTicker(s.cfg.HealthInterval)
+	defer ticker.Stop()
+	for ; ; <-ticker.C {
+		status := healthpb.HealthCheckResponse_SERVING
+		if s.health.Ready(context.Background()).Status == health.StatusFail {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		server.SetServingStatus("", status)
+		server.SetServingStatus(statsv1.StatsService_ServiceDesc.ServiceName, status)
+	}
+}
@@ -0,0 +1,166 @@
+package rpc
+
+import (
+	"consumer/internal/feed"
+	"consumer/internal/models"
+	"consumer/internal/services"
+	statsv1 "consumer/proto/stats/v1"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Most keys times windows a single batch can read
+const maxBatchItems = 100
+
+type statsServer struct {
+	statsv1.UnimplementedStatsServiceServer
+	stats     *services.StatsService
+	snapshots feed.StatsReader // reads without the cache, so that snapshots include every update up to their seq
+	hub       *feed.Hub
+	cfg       Config
+}
+
+func (s *statsServer) GetStats(ctx context.Context, req *statsv1.GetStatsRequest) (*statsv1.GetStatsResponse, error) {
+	key := strings.ToUpper(req.Key)
+	if !services.IsValidPeriod(req.Window) {
+		return nil, status.Error(codes.InvalidArgument, "invalid period window provided")
+	}
+	if !services.IsValidToken(key) && !services.IsValidPair(key) {
+		return nil, status.Error(codes.InvalidArgument, "invalid token or pair provided")
+	}
+
+	statsKey := fmt.Sprintf("stats:%s:%s", key, req.Window)
+	stats, err := s.stats.GetStats(ctx, statsKey)
+	if err != nil {
+		return nil, statusOf(errors.Wrapf(err, "failed to get stats from the stats service for the key %s", statsKey))
+	}
+	return &statsv1.GetStatsResponse{Stats: feed.StatsProto(stats)}, nil
+}
+
+// Results are ordered by key and then by window as requested
+func (s *statsServer) BatchGetStats(ctx context.Context, req *statsv1.BatchGetStatsRequest) (*statsv1.BatchGetStatsResponse, error) {
+	if len(req.Keys) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no keys provided")
+	}
+	windows := req.Windows
+	if len(windows) == 0 {
+		windows = models.Windows
+	}
+	if len(req.Keys)*len(windows) > maxBatchItems {
+		return nil, status.Errorf(codes.InvalidArgument, "too many keys and windows, at most %d combinations are allowed", maxBatchItems)
+	}
+
+	var results []*statsv1.BatchGetStatsResult
+	var statsKeys []string
+	for _, key := range req.Keys {
+		key = strings.ToUpper(key)
+		validKey := services.IsValidToken(key) || services.IsValidPair(key)
+		for _, window := range windows {
+			result := &statsv1.BatchGetStatsResult{Key: key, Window: window}
+			switch {
+			case !services.IsValidPeriod(window):
+				result.Error = "invalid period window provided"
+			case !validKey:
+				result.Error = "invalid token or pair provided"
+			default:
+				statsKeys = append(statsKeys, fmt.Sprintf("stats:%s:%s", key, window))
+			}
+			results = append(results, result)
+		}
+	}
+
+	stats, keyErrs, err := s.stats.GetStatsBatch(ctx, statsKeys)
+	if err != nil {
+		return nil, statusOf(errors.Wrapf(err, "failed to get stats from the stats service for %d keys", len(statsKeys)))
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			continue
+		}
+		statsKey := fmt.Sprintf("stats:%s:%s", result.Key, result.Window)
+		if err, ok := keyErrs[statsKey]; ok {
+			log.Println(errors.Wrapf(err, "failed to get stats from the stats service for the key %s", statsKey).Error())
+			result.Error = "failed to read stats"
+			continue
+		}
+		result.Stats = feed.StatsProto(stats[statsKey])
+	}
+	return &statsv1.BatchGetStatsResponse{Results: results}, nil
+}
+
+// Send the snapshot of the subscribed keys, or the missed updates when resuming from the last seq,
+// followed by the live updates until the client cancels the call or falls behind
+func (s *statsServer) SubscribeStats(req *statsv1.SubscribeStatsRequest, stream grpc.ServerStreamingServer[statsv1.StreamMessage]) error {
+	keys := req.Keys
+	if len(keys) == 0 {
+		keys = feed.AllKeys
+	}
+	subs, err := feed.BuildSubscriptions(keys, req.Windows)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	listener := s.hub.Subscribe(subs, s.cfg.QueueSize)
+	defer listener.Close()
+
+	ctx := stream.Context()
+	initial, err := feed.LoadInitial(ctx, s.hub, s.snapshots, subs, req.LastSeq)
+	if err != nil {
+		return statusOf(err)
+	}
+	for _, entry := range initial.Replay {
+		if err := stream.Send(entry.Envelope.Proto()); err != nil {
+			return err
+		}
+	}
+	for _, message := range initial.Messages {
+		if err := stream.Send(message.Proto()); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-listener.Overflow():
+			return status.Errorf(codes.ResourceExhausted, "subscriber is too slow, queue of %d updates is full", listener.QueueSize())
+		case entry := <-listener.Updates():
+			if entry.Update.Seq <= initial.Seq {
+				continue
+			}
+			if err := stream.Send(entry.Envelope.Proto()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Status of the error with the code of its kind, the details of server errors are only logged
+func statusOf(err error) error {
+	var domainErr *services.Error
+	detail := ""
+	if errors.As(err, &domainErr) {
+		detail = domainErr.Detail
+	}
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		return status.Error(codes.NotFound, detail)
+	case errors.Is(err, services.ErrInvalidWindow), errors.Is(err, services.ErrInvalidToken),
+		errors.Is(err, services.ErrInvalidPair), errors.Is(err, services.ErrInvalidRequest):
+		return status.Error(codes.InvalidArgument, detail)
+	case errors.Is(err, services.ErrStoreUnavailable):
+		log.Println(err.Error())
+		return status.Error(codes.Unavailable, detail)
+	default:
+		log.Println(err.Error())
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
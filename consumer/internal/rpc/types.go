@@ -0,0 +1,22 @@
+package rpc
+
+import (
+	"consumer/internal/auth"
+	"consumer/internal/models"
+	"consumer/internal/repositories"
+	"time"
+)
+
+type Config struct {
+	QueueSize      int           // updates buffered per subscription, the stream is ended when its queue overflows
+	HealthInterval time.Duration // how often the readiness checks update the status of the health service
+}
+
+// Authentication and rate limiting of the calls, with the same credentials and buckets as the REST API.
+// A limit with zero rate is disabled
+type AccessConfig struct {
+	Authenticator auth.Authenticator   // authentication is disabled when nil
+	Limiter       repositories.Limiter // rate limiting is disabled when nil
+	KeyLimit      models.RateLimit
+	IPLimit       models.RateLimit
+}
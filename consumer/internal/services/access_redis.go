@@ -0,0 +1,105 @@
+package services
+
+import (
+	"consumer/internal/models"
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+// Hash of the API keys issued at runtime, the hex-encoded SHA-256 hash of a key to its name
+const apiKeysKey = "auth:api_keys"
+
+// Prefix of the token buckets, e.g. "ratelimit:key:api_key:dashboard"
+const rateLimitPrefix = "ratelimit:"
+
+// Refill the token bucket for the time since its last update and take a token if there is one.
+// The bucket is a hash of the tokens left and the update time in milliseconds, it expires once it would be full.
+// Returns whether a token was taken and the tokens left as a string, since Redis truncates Lua numbers to integers
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// API keys and rate limits shared by the API replicas
+type RedisAccessRepo struct {
+	rdb *redis.Client
+}
+
+func NewRedisAccessRepo(cfg RedisConfig) *RedisAccessRepo {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+	})
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		log.Printf("failed to instrument redis client with tracing: %v\n", err)
+	}
+	return &RedisAccessRepo{rdb}
+}
+
+// Name of the API key with the hex-encoded SHA-256 hash, empty when there is no such key
+func (r *RedisAccessRepo) LookupAPIKey(ctx context.Context, hash string) (string, error) {
+	name, err := r.rdb.HGet(ctx, apiKeysKey, hash).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the API key from %s", apiKeysKey)
+	}
+	return name, nil
+}
+
+// Take a token from the bucket of the key, e.g. "ip:10.0.0.1", in a single atomic script run.
+// The times of the replicas are used to refill the buckets, so their clocks should be in sync
+func (r *RedisAccessRepo) TakeToken(ctx context.Context, key string, limit models.RateLimit) (*models.Allowance, error) {
+	now := time.Now().UnixMilli()
+	res, err := takeTokenScript.Run(ctx, r.rdb, []string{rateLimitPrefix + key}, limit.Rate, limit.Burst, now).Slice()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to take a token from the bucket of %s", key)
+	}
+	if len(res) != 2 {
+		return nil, errors.Errorf("unexpected reply %v taking a token from the bucket of %s", res, key)
+	}
+	allowed, _ := res[0].(int64)
+	str, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the tokens left in the bucket of %s", key)
+	}
+
+	allowance := &models.Allowance{
+		Allowed:   allowed == 1,
+		Remaining: int(tokens),
+		Reset:     secondsOf((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowance.Allowed {
+		allowance.RetryAfter = secondsOf((1 - tokens) / limit.Rate)
+	}
+	return allowance, nil
+}
+
+// Duration of the seconds rounded up to a whole second, as the RateLimit headers carry whole seconds
+func secondsOf(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds)) * time.Second
+}
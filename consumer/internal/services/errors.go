@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInterval = errors.New("erroneous interval received")
+)
+
+// Kinds of the domain errors, matched with errors.Is
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrInvalidWindow    = errors.New("invalid period window")
+	ErrInvalidToken     = errors.New("invalid token")
+	ErrInvalidPair      = errors.New("invalid pair")
+	ErrInvalidRequest   = errors.New("invalid request")
+	ErrStoreUnavailable = errors.New("stats store unavailable")
+)
+
+// Domain error of a kind with a detail that is safe to show to clients.
+// The cause, if any, is only for logs, e.g. the Redis error behind ErrStoreUnavailable
+type Error struct {
+	Kind   error
+	Detail string
+	Err    error
+}
+
+func NewError(kind error, format string, args ...any) *Error {
+	return &Error{Kind: kind, Detail: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Report the failure to read or write the store as ErrStoreUnavailable, domain errors are returned as they are
+func storeError(err error) error {
+	var domainErr *Error
+	if err == nil || errors.As(err, &domainErr) {
+		return err
+	}
+	return &Error{Kind: ErrStoreUnavailable, Detail: "the stats store is unavailable", Err: err}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"consumer/internal/models"
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Fields of the buckets, the volume and tx count of every key and the sold and bought volumes of the tokens.
+// The native amounts of the tokens of a key are fields too, see amountField
+const (
+	fieldVolume       = "volume"
+	fieldTxCount      = "tx_count"
+	fieldSoldVolume   = "sold_volume"
+	fieldBoughtVolume = "bought_volume"
+)
+
+// Whether a token "ETH" or a pair "ETH-BTC" or "BTC/ETH" key has flows, only tokens do
+func hasFlows(key string) bool {
+	return !strings.ContainsAny(key, "-/")
+}
+
+// Tokens of a token "ETH" or a pair "ETH-BTC" or "BTC/ETH" key
+func tokensOf(key string) []string {
+	return strings.FieldsFunc(key, func(r rune) bool { return r == '-' || r == '/' })
+}
+
+// Field of the volume a token is swapped in on a side of the swap
+func sideField(side string) string {
+	if side == models.SideSold {
+		return fieldSoldVolume
+	}
+	return fieldBoughtVolume
+}
+
+// Field of the amount of a token swapped in its native units, e.g. "amount:ETH"
+func amountField(token string) string {
+	return "amount:" + token
+}
+
+// MGETs of the fields of a key's buckets queued on a pipeline, their values are read once it's executed
+type bucketReads struct {
+	volume  *redis.SliceCmd
+	txCount *redis.SliceCmd
+	sold    *redis.SliceCmd // sold and bought are only read for the keys with flows
+	bought  *redis.SliceCmd
+	amounts map[string]*redis.SliceCmd
+}
+
+func queueBucketReads(ctx context.Context, pipe redis.Pipeliner, bucketKeys []string, key string) *bucketReads {
+	mget := func(field string) *redis.SliceCmd {
+		keys := make([]string, len(bucketKeys))
+		for i, bucketKey := range bucketKeys {
+			keys[i] = bucketKey + ":" + field
+		}
+		return pipe.MGet(ctx, keys...)
+	}
+
+	reads := &bucketReads{
+		volume:  mget(fieldVolume),
+		txCount: mget(fieldTxCount),
+		amounts: make(map[string]*redis.SliceCmd),
+	}
+	if hasFlows(key) {
+		reads.sold = mget(fieldSoldVolume)
+		reads.bought = mget(fieldBoughtVolume)
+	}
+	for _, token := range tokensOf(key) {
+		reads.amounts[token] = mget(amountField(token))
+	}
+	return reads
+}
+
+// First error Redis replied to the reads with
+func (b *bucketReads) err() error {
+	cmds := []*redis.SliceCmd{b.volume, b.txCount, b.sold, b.bought}
+	for _, cmd := range b.amounts {
+		cmds = append(cmds, cmd)
+	}
+	for _, cmd := range cmds {
+		if cmd != nil && cmd.Err() != nil {
+			return cmd.Err()
+		}
+	}
+	return nil
+}
+
+// Stats of the i-th bucket
+func (b *bucketReads) bucket(i int) models.Stats {
+	stats := models.Stats{
+		Volume:  parseVolume(b.volume.Val()[i]),
+		TxCount: parseTxCount(b.txCount.Val()[i]),
+		Amounts: make(map[string]float64, len(b.amounts)),
+	}
+	if b.sold != nil {
+		stats.Flows = newFlows(parseVolume(b.sold.Val()[i]), parseVolume(b.bought.Val()[i]))
+	}
+	for token, cmd := range b.amounts {
+		stats.Amounts[token] = parseVolume(cmd.Val()[i])
+	}
+	return stats
+}
+
+// Stats of all buckets summed
+func (b *bucketReads) sum() models.Stats {
+	sum := models.Stats{Amounts: make(map[string]float64, len(b.amounts))}
+	var sold, bought float64
+	for i := range b.volume.Val() {
+		stats := b.bucket(i)
+		sum.Volume += stats.Volume
+		sum.TxCount += stats.TxCount
+		if stats.Flows != nil {
+			sold += stats.SoldVolume
+			bought += stats.BoughtVolume
+		}
+		for token, amount := range stats.Amounts {
+			sum.Amounts[token] += amount
+		}
+	}
+	if b.sold != nil {
+		sum.Flows = newFlows(sold, bought)
+	}
+	return sum
+}
+
+func newFlows(sold, bought float64) *models.Flows {
+	return &models.Flows{SoldVolume: sold, BoughtVolume: bought, NetFlow: bought - sold}
+}
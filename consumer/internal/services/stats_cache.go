@@ -0,0 +1,58 @@
+package services
+
+import (
+	"consumer/internal/models"
+	"maps"
+	"sync"
+	"time"
+)
+
+// In-process cache of the window stats read from Redis
+type statsCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedStats
+}
+
+type cachedStats struct {
+	stats   models.Stats
+	expires time.Time
+}
+
+func newStatsCache() *statsCache {
+	return &statsCache{entries: make(map[string]cachedStats)}
+}
+
+// Copy of the cached stats of the key, false when they aren't cached or have expired
+func (c *statsCache) get(key string, now time.Time) (*models.Stats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		return nil, false
+	}
+	return copyStats(&entry.stats), true
+}
+
+// Cache the stats of the key until the expiration time, the expired entries are dropped along the way
+func (c *statsCache) set(key string, stats *models.Stats, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedStats{*copyStats(stats), expires}
+}
+
+// Deep copy of the stats, so that the callers sharing a read or a cache entry can't change each other's flows and amounts
+func copyStats(stats *models.Stats) *models.Stats {
+	copied := *stats
+	if stats.Flows != nil {
+		flows := *stats.Flows
+		copied.Flows = &flows
+	}
+	copied.Amounts = maps.Clone(stats.Amounts)
+	return &copied
+}
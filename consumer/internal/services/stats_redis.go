@@ -4,14 +4,84 @@ import (
 	"consumer/internal/models"
 	"consumer/internal/utils"
 	"context"
-	"fmt"
+	"log"
+	"maps"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
+// Unix time in milliseconds of the last aggregated swap
+const lastUpdateKey = "stats:last_update"
+
+// How long the union of the leaderboard buckets is kept after it's read, it's rebuilt by every read
+const leaderboardUnionTTL = time.Minute
+
+// Bucket layout of a period window, buckets expire once they leave the window
+type windowLayout struct {
+	bucketSize time.Duration
+	buckets    int
+}
+
+// - 5min window divided into 5 buckets of 1 minute each
+// - 1h window divided into 12 buckets of 5 minutes each
+// - 24h window - 24 buckets of 1 hour each
+var windowLayouts = map[string]windowLayout{
+	"5min": {time.Minute, 5},
+	"1h":   {5 * time.Minute, 12},
+	"24h":  {time.Hour, 24},
+}
+
+func (l windowLayout) ttl() time.Duration {
+	return l.bucketSize * time.Duration(l.buckets)
+}
+
+// Start times of the buckets of the window ending now, the current bucket first
+func (l windowLayout) bucketStarts(now time.Time) []time.Time {
+	current := now.Truncate(l.bucketSize)
+	starts := make([]time.Time, l.buckets)
+	for i := range starts {
+		starts[i] = current.Add(-time.Duration(i) * l.bucketSize)
+	}
+	return starts
+}
+
+// Start time of the oldest bucket that is still retained
+func (l windowLayout) retainedSince(now time.Time) time.Time {
+	return now.Truncate(l.bucketSize).Add(-time.Duration(l.buckets-1) * l.bucketSize)
+}
+
+// Window with the finest buckets that are retained since the time, the window with the longest retention
+// and false when none of them are
+func rangeWindow(from, now time.Time) (string, bool) {
+	for _, window := range models.Windows {
+		if !from.Before(windowLayouts[window].retainedSince(now)) {
+			return window, true
+		}
+	}
+	return models.Windows[len(models.Windows)-1], false
+}
+
+// Token or pair key and window of a key like "stats:ETH:5min"
+func splitWindowKey(key string) (string, string, bool) {
+	parts := strings.Split(key, ":")
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// Layout of the window of a key like "stats:ETH:5min"
+func layoutOf(key string) (windowLayout, bool) {
+	layout, ok := windowLayouts[key[strings.LastIndex(key, ":")+1:]]
+	return layout, ok
+}
+
 type RedisStatsRepo struct {
 	rdb  *redis.Client
 	pipe redis.Pipeliner
@@ -22,140 +92,339 @@ func NewRedisStatsRepo(cfg RedisConfig) *RedisStatsRepo {
 		Addr:     cfg.Addr,
 		Password: cfg.Password,
 	})
+	// Every Redis command and pipeline becomes a child span of the caller's span
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		log.Printf("failed to instrument redis client with tracing: %v\n", err)
+	}
 	pipe := rdb.Pipeline()
 	return &RedisStatsRepo{rdb, pipe}
 }
 
 // Get stats via a key "stats:ETH:5min" with consideration to the window bucket
 // Each bucket key is a postfix for the original key
-// O(k) reads, where k = buckets in window (5, 12, or 24 max)
+// O(k) reads, where k = buckets in window (5, 12, or 24 max), in a single round-trip.
+// Returns ErrNotFound when all the buckets have expired, i.e. there were no swaps of the key in the window
 func (r *RedisStatsRepo) GetStats(ctx context.Context, key string) (*models.Stats, error) {
-	var volume float64
-	var txCount int64
-
-	bucketKeys := getWindowBuckets(key)
-	for _, bucketKey := range bucketKeys {
-		volResult := r.rdb.Get(ctx, bucketKey+":volume")
-		if volResult.Err() == nil {
-			if val, err := volResult.Float64(); err == nil {
-				volume += val
-			}
-		}
-
-		countResult := r.rdb.Get(ctx, bucketKey+":tx_count")
-		if countResult.Err() == nil {
-			if val, err := countResult.Int64(); err == nil {
-				txCount += val
-			}
-		}
+	stats, err := r.getWindowStats(ctx, []string{key})
+	if err != nil {
+		return nil, err
 	}
-
-	return &models.Stats{
-		Volume:  volume,
-		TxCount: txCount,
-	}, nil
+	// Every bucket counts at least one swap, so there are none when the count is zero
+	if stats[key].TxCount == 0 {
+		statsKey, window, _ := splitWindowKey(key)
+		return nil, NewError(ErrNotFound, "no swaps of %s in the %s window", statsKey, window)
+	}
+	return stats[key], nil
 }
 
-// Method to aggregate stats data
-// - O(1) writes: 6 Redis operations per swap
+// Method to aggregate stats data, returns the up-to-date stats of the whole windows keyed by period window.
+// The key is also scored in the leaderboard buckets of its kind, sorted sets ranking the keys of the bucket
+// - O(1) writes: 4 bucket and 4 leaderboard operations per window
+// - O(k) reads of the window totals in a single round-trip
 // - Fixed memory
 // - Automatic cleanup: Redis TTL handles expiration
 func (r *RedisStatsRepo) UpsertStats(
 	ctx context.Context,
 	key string,
-	value float64,
+	delta models.StatsDelta,
 ) (map[string]*models.Stats, error) {
-	data := make(map[string]*models.Stats)
 	now := time.Now()
 
-	// 5min buckets (60 seconds each)
-	bucket5min := now.Truncate(time.Minute).Unix()
-	key5minPrefix := utils.BuildSemicolonKey(key, "5min")
-	key5min := fmt.Sprintf("stats:%s:%d", key5minPrefix, bucket5min)
+	windowKeys := make(map[string]string, len(models.Windows))
+	for _, window := range models.Windows {
+		layout := windowLayouts[window]
+		windowKey := "stats:" + utils.BuildSemicolonKey(key, window)
+		bucketKey := buildBucketKey(windowKey, now.Truncate(layout.bucketSize).Unix())
 
-	// 1h buckets (5 minutes each)
-	bucket1h := now.Truncate(5 * time.Minute).Unix()
-	key1hPrefix := utils.BuildSemicolonKey(key, "1h")
-	key1h := fmt.Sprintf("stats:%s:%d", key1hPrefix, bucket1h)
+		r.pipe.IncrByFloat(ctx, bucketKey+":"+fieldVolume, delta.UsdValue)
+		r.pipe.Expire(ctx, bucketKey+":"+fieldVolume, layout.ttl())
+		r.pipe.Incr(ctx, bucketKey+":"+fieldTxCount)
+		r.pipe.Expire(ctx, bucketKey+":"+fieldTxCount, layout.ttl())
+		if delta.Side != "" {
+			r.pipe.IncrByFloat(ctx, bucketKey+":"+sideField(delta.Side), delta.UsdValue)
+			r.pipe.Expire(ctx, bucketKey+":"+sideField(delta.Side), layout.ttl())
+		}
+		for token, amount := range delta.Amounts {
+			r.pipe.IncrByFloat(ctx, bucketKey+":"+amountField(token), amount)
+			r.pipe.Expire(ctx, bucketKey+":"+amountField(token), layout.ttl())
+		}
+
+		if kind := leaderboardOf(key); kind != "" {
+			boardKey := buildBucketKey(buildLeaderboardKey(kind, window), now.Truncate(layout.bucketSize).Unix())
+			r.pipe.ZIncrBy(ctx, boardKey+":"+models.RankByVolume, delta.UsdValue, key)
+			r.pipe.Expire(ctx, boardKey+":"+models.RankByVolume, layout.ttl())
+			r.pipe.ZIncrBy(ctx, boardKey+":"+models.RankByTxCount, 1, key)
+			r.pipe.Expire(ctx, boardKey+":"+models.RankByTxCount, layout.ttl())
+		}
+		windowKeys[window] = windowKey
+	}
 
-	// 24h buckets (1 hour each)
-	bucket24h := now.Truncate(time.Hour).Unix()
-	key24hPrefix := utils.BuildSemicolonKey(key, "24h")
-	key24h := fmt.Sprintf("stats:%s:%d", key24hPrefix, bucket24h)
+	r.pipe.Set(ctx, lastUpdateKey, now.UnixMilli(), 24*time.Hour)
 
-	vol5min, err := r.pipe.IncrByFloat(ctx, key5min+":volume", value).Result()
+	_, err := r.pipe.Exec(ctx)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to increment key %s", key5min+":volume")
+		return nil, errors.Wrapf(err, "failed to exec a pipeline for key %s and value %v", key, delta.UsdValue)
 	}
-	r.pipe.Expire(ctx, key5min+":volume", 5*time.Minute)
 
-	count5min, err := r.pipe.Incr(ctx, key5min+":tx_count").Result()
+	totals, err := r.getWindowStats(ctx, slices.Collect(maps.Values(windowKeys)))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to increment key %s", key5min+":tx_count")
+		return nil, errors.Wrapf(err, "failed to get window totals for key %s", key)
 	}
-	r.pipe.Expire(ctx, key5min+":tx_count", 5*time.Minute)
-	data[key5minPrefix] = &models.Stats{Volume: vol5min, TxCount: count5min}
 
-	vol1h, err := r.pipe.IncrByFloat(ctx, key1h+":volume", value).Result()
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to increment key %s", key1h+":volume")
+	data := make(map[string]*models.Stats, len(windowKeys))
+	for window, windowKey := range windowKeys {
+		data[window] = totals[windowKey]
 	}
-	r.pipe.Expire(ctx, key1h+":volume", 60*time.Minute)
+	return data, nil
+}
 
-	count1h, err := r.pipe.Incr(ctx, key1h+":tx_count").Result()
+// Get the stats of many window keys like "stats:ETH:5min" at once, in a single round-trip.
+// Keys Redis failed to read are returned with their errors, the error is returned when the round-trip failed
+func (r *RedisStatsRepo) GetStatsBatch(ctx context.Context, keys []string) (map[string]*models.Stats, map[string]error, error) {
+	return r.readWindowStats(ctx, keys)
+}
+
+// Sum the buckets of every window key like "stats:ETH:5min" in a single pipelined round-trip.
+// Fails when any of the keys fails to read
+func (r *RedisStatsRepo) getWindowStats(ctx context.Context, keys []string) (map[string]*models.Stats, error) {
+	stats, keyErrs, err := r.readWindowStats(ctx, keys)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to increment key %s", key1h+":tx_count")
+		return nil, err
 	}
-	r.pipe.Expire(ctx, key1h+":tx_count", 60*time.Minute)
-	data[key1hPrefix] = &models.Stats{Volume: vol1h, TxCount: count1h}
+	for key, err := range keyErrs {
+		return nil, errors.Wrapf(err, "failed to read window key %s", key)
+	}
+	return stats, nil
+}
 
-	vol24h, err := r.pipe.IncrByFloat(ctx, key24h+":volume", value).Result()
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to increment key %s", key24h+":volume")
+// Each window is read with an MGET per field of its buckets, the sold and bought volumes are only read for tokens.
+// Redis replies with an error to a single command of the pipeline without failing the others,
+// any other error, e.g. a lost connection, fails all of them
+func (r *RedisStatsRepo) readWindowStats(ctx context.Context, keys []string) (map[string]*models.Stats, map[string]error, error) {
+	pipe := r.rdb.Pipeline()
+	reads := make(map[string]*bucketReads, len(keys))
+	for _, key := range keys {
+		bucketKeys := getWindowBuckets(key)
+		statsKey, _, ok := splitWindowKey(key)
+		if len(bucketKeys) == 0 || !ok {
+			continue
+		}
+		reads[key] = queueBucketReads(ctx, pipe, bucketKeys, statsKey)
 	}
-	r.pipe.Expire(ctx, key24h+":volume", 24*time.Hour)
 
-	count24h, err := r.pipe.Incr(ctx, key24h+":tx_count").Result()
+	if len(reads) > 0 {
+		_, err := pipe.Exec(ctx)
+		var replyErr redis.Error
+		if err != nil && !errors.As(err, &replyErr) {
+			return nil, nil, errors.Wrapf(err, "failed to exec a pipeline reading %d window keys", len(keys))
+		}
+	}
+
+	stats := make(map[string]*models.Stats, len(keys))
+	keyErrs := make(map[string]error)
+	for _, key := range keys {
+		keyReads := reads[key]
+		if keyReads == nil {
+			stats[key] = &models.Stats{}
+			continue
+		}
+		if err := keyReads.err(); err != nil {
+			keyErrs[key] = err
+			continue
+		}
+		sum := keyReads.sum()
+		stats[key] = &sum
+	}
+	return stats, keyErrs, nil
+}
+
+// Get the stats of a token "ETH" or pair "ETH-BTC" key in the time range, summed from the buckets of the window
+// with the finest buckets retained since the start of the range. The range ends now at the latest
+func (r *RedisStatsRepo) GetRangeStats(ctx context.Context, key string, from, to time.Time) (*models.RangeStats, error) {
+	now := time.Now()
+	if to.After(now) {
+		to = now
+	}
+	window, covered := rangeWindow(from, now)
+	layout := windowLayouts[window]
+
+	start := from.Truncate(layout.bucketSize)
+	if !covered {
+		start = layout.retainedSince(now)
+	}
+	end := start
+	windowKey := "stats:" + utils.BuildSemicolonKey(key, window)
+	var bucketKeys []string
+	for ; end.Before(to); end = end.Add(layout.bucketSize) {
+		bucketKeys = append(bucketKeys, buildBucketKey(windowKey, end.Unix()))
+	}
+
+	stats := &models.RangeStats{
+		Key:           key,
+		From:          start.UTC(),
+		To:            end.UTC(),
+		BucketSeconds: int64(layout.bucketSize.Seconds()),
+		Partial:       !covered,
+	}
+	if len(bucketKeys) == 0 {
+		return stats, nil
+	}
+
+	pipe := r.rdb.Pipeline()
+	reads := queueBucketReads(ctx, pipe, bucketKeys, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.Wrapf(err, "failed to exec a pipeline reading %d buckets of key %s", len(bucketKeys), windowKey)
+	}
+	stats.Stats = reads.sum()
+	return stats, nil
+}
+
+// Get the per-bucket stats of a window key like "stats:ETH:1h", the oldest bucket first.
+// Buckets without swaps are filled with zeros, so there is always the full number of buckets of the window
+func (r *RedisStatsRepo) GetSeries(ctx context.Context, key string) (*models.Series, error) {
+	statsKey, window, ok := splitWindowKey(key)
+	layout, known := layoutOf(key)
+	if !ok || !known {
+		return nil, errors.Errorf("invalid window key %s", key)
+	}
+	starts := layout.bucketStarts(time.Now())
+	slices.Reverse(starts)
+
+	bucketKeys := make([]string, len(starts))
+	for i, start := range starts {
+		bucketKeys[i] = buildBucketKey(key, start.Unix())
+	}
+	pipe := r.rdb.Pipeline()
+	reads := queueBucketReads(ctx, pipe, bucketKeys, statsKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.Wrapf(err, "failed to exec a pipeline reading the buckets of key %s", key)
+	}
+
+	series := &models.Series{
+		Key:           statsKey,
+		Window:        window,
+		BucketSeconds: int64(layout.bucketSize.Seconds()),
+		Buckets:       make([]models.Bucket, len(starts)),
+	}
+	buckets := series.Buckets
+	for i, start := range starts {
+		buckets[i].Start = start.UTC()
+		buckets[i].Stats = reads.bucket(i)
+	}
+	return series, nil
+}
+
+// Get up to limit top tokens or pairs of the window ranked by volume or tx count.
+// The leaderboard buckets of the window are summed into a sorted set the top keys are read from,
+// then the stats of those keys are read, so an entry may include swaps aggregated after it was ranked
+func (r *RedisStatsRepo) GetLeaderboard(ctx context.Context, window, kind, by string, limit int) (*models.Leaderboard, error) {
+	layout, ok := windowLayouts[window]
+	if !ok {
+		return nil, errors.Errorf("unknown window %s", window)
+	}
+	boardKey := buildLeaderboardKey(kind, window)
+	var bucketKeys []string
+	for _, start := range layout.bucketStarts(time.Now()) {
+		bucketKeys = append(bucketKeys, buildBucketKey(boardKey, start.Unix())+":"+by)
+	}
+
+	unionKey := boardKey + ":" + by
+	tx := r.rdb.TxPipeline()
+	tx.ZUnionStore(ctx, unionKey, &redis.ZStore{Keys: bucketKeys, Aggregate: "SUM"})
+	tx.Expire(ctx, unionKey, leaderboardUnionTTL)
+	rankCmd := tx.ZRevRange(ctx, unionKey, 0, int64(limit-1))
+	if _, err := tx.Exec(ctx); err != nil {
+		return nil, errors.Wrapf(err, "failed to exec a transaction ranking the %s of window %s by %s", kind, window, by)
+	}
+
+	ranked := rankCmd.Val()
+	statsKeys := make([]string, len(ranked))
+	for i, key := range ranked {
+		statsKeys[i] = "stats:" + utils.BuildSemicolonKey(key, window)
+	}
+	stats, err := r.getWindowStats(ctx, statsKeys)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to increment key %s", key24h+":tx_count")
+		return nil, errors.Wrapf(err, "failed to get stats of the top %s of window %s", kind, window)
 	}
-	r.pipe.Expire(ctx, key24h+":tx_count", 24*time.Hour)
-	data[key24hPrefix] = &models.Stats{Volume: vol24h, TxCount: count24h}
 
-	_, err = r.pipe.Exec(ctx)
+	board := &models.Leaderboard{
+		Window:  window,
+		Type:    kind,
+		By:      by,
+		Entries: make([]models.LeaderboardEntry, len(ranked)),
+	}
+	for i, key := range ranked {
+		board.Entries[i] = models.LeaderboardEntry{Rank: i + 1, Key: key, Stats: *stats[statsKeys[i]]}
+	}
+	return board, nil
+}
+
+// Time of the last aggregated swap, zero time if there were none within the last 24h
+func (r *RedisStatsRepo) LastUpdate(ctx context.Context) (time.Time, error) {
+	ms, err := r.rdb.Get(ctx, lastUpdateKey).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to exec a pipeline for key %s and value %v", key, value)
+		return time.Time{}, errors.Wrapf(err, "failed to get key %s", lastUpdateKey)
 	}
+	return time.UnixMilli(ms), nil
+}
 
-	return data, err
+func (r *RedisStatsRepo) Ping(ctx context.Context) error {
+	return r.rdb.Ping(ctx).Err()
 }
 
-// Get the bucket keys based on the current time and provided original key
-// - 5min window divided into 5 buckets of 1 minute each
-// - 1h window divided into 12 buckets of 5 minutes each
-// - 24h window - 24 buckets of 1 hour each
+// Get the bucket keys based on the current time and provided original key, the current bucket first
 func getWindowBuckets(originalKey string) []string {
-	now := time.Now()
+	layout, ok := layoutOf(originalKey)
+	if !ok {
+		return nil
+	}
 	var buckets []string
-	if utils.Contains(originalKey, ":5min") {
-		for i := 0; i < 5; i++ {
-			bucket := now.Add(-time.Duration(i) * time.Minute).Truncate(time.Minute).Unix()
-			buckets = append(buckets, buildBucketKey(originalKey, bucket))
-		}
+	for _, start := range layout.bucketStarts(time.Now()) {
+		buckets = append(buckets, buildBucketKey(originalKey, start.Unix()))
 	}
-	if utils.Contains(originalKey, ":1h") {
-		for i := 0; i < 12; i++ {
-			bucket := now.Add(-time.Duration(i) * 5 * time.Minute).Truncate(5 * time.Minute).Unix()
-			buckets = append(buckets, buildBucketKey(originalKey, bucket))
+	return buckets
+}
+
+// Volume of a bucket read with MGET, 0 when the bucket has expired
+func parseVolume(val any) float64 {
+	if str, ok := val.(string); ok {
+		if volume, err := strconv.ParseFloat(str, 64); err == nil {
+			return volume
 		}
 	}
-	if utils.Contains(originalKey, ":24h") {
-		for i := 0; i < 24; i++ {
-			bucket := now.Add(-time.Duration(i) * time.Hour).Truncate(time.Hour).Unix()
-			buckets = append(buckets, buildBucketKey(originalKey, bucket))
+	return 0
+}
+
+// Tx count of a bucket read with MGET, 0 when the bucket has expired
+func parseTxCount(val any) int64 {
+	if str, ok := val.(string); ok {
+		if count, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return count
 		}
 	}
-	return buckets
+	return 0
+}
+
+// Kind of the leaderboard a token "ETH" or a pair "ETH-BTC" key is ranked in.
+// Undirected pairs "BTC/ETH" aren't ranked, so that every swap is ranked once among the pairs
+func leaderboardOf(key string) string {
+	switch {
+	case strings.Contains(key, "/"):
+		return ""
+	case strings.Contains(key, "-"):
+		return models.LeaderboardPairs
+	default:
+		return models.LeaderboardTokens
+	}
+}
+
+// Key of the leaderboard of the kind in the window, e.g. "leaderboard:pairs:1h".
+// Its buckets are sorted sets like "leaderboard:pairs:1h:1735689600:volume"
+func buildLeaderboardKey(kind, window string) string {
+	return "leaderboard:" + kind + ":" + window
 }
 
 // Convert the original key to the bucket key
@@ -5,62 +5,253 @@ import (
 	"consumer/internal/repositories"
 	"consumer/internal/utils"
 	"context"
-	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
-var (
-	ErrInterval = errors.New("erroneous interval received")
-)
+var tracer = otel.Tracer("consumer/internal/services")
+
+// How long a single read of the updates stream waits for new entries
+const updatesBlockTimeout = 5 * time.Second
 
 type StatsService struct {
-	repo repositories.StatsRepo
+	repo     repositories.StatsRepo
+	cacheCfg CacheConfig
+	cache    *statsCache
+	reads    singleflight.Group
 }
 
-func NewStatsService(r repositories.StatsRepo) *StatsService {
-	return &StatsService{r}
+func NewStatsService(r repositories.StatsRepo, cacheCfg CacheConfig) *StatsService {
+	return &StatsService{repo: r, cacheCfg: cacheCfg, cache: newStatsCache()}
 }
 
+// Get the stats of a key like "stats:ETH:5min" from the cache or read them through it.
+// Returns ErrNotFound when there were no swaps of the key in the window.
+// Concurrent reads of the same key are coalesced into a single read of the store,
+// which isn't canceled along with the request that started it, since others may be waiting for it
 func (s *StatsService) GetStats(ctx context.Context, key string) (*models.Stats, error) {
-	return s.repo.GetStats(ctx, key)
-}
+	if stats, ok := s.cache.get(key, time.Now()); ok {
+		return stats, nil
+	}
 
-func (s *StatsService) ProcessSwapEvent(
-	ctx context.Context,
-	event models.SwapEvent,
-	broadcast chan []byte,
-) error {
-	data, err := s.repo.UpsertStats(ctx, event.TokenFrom, event.UsdValue)
+	v, err, _ := s.reads.Do(key, func() (any, error) {
+		stats, err := s.repo.GetStats(context.WithoutCancel(ctx), key)
+		if err != nil {
+			return nil, storeError(err)
+		}
+		if ttl := s.CacheTTL(key[strings.LastIndex(key, ":")+1:]); ttl > 0 && stats != nil {
+			s.cache.set(key, stats, time.Now().Add(ttl))
+		}
+		return stats, nil
+	})
 	if err != nil {
-		return errors.Wrapf(err, "failed to upsert stats for tokenFrom %s and usd value %v", event.TokenFrom, event.UsdValue)
+		return nil, err
 	}
-	statsTokenFrom, err := json.Marshal(data)
-	if err != nil {
-		return errors.Wrapf(err, "failed to marshal stats data for tokenFrom %s and usd value %v", event.TokenFrom, event.UsdValue)
+	stats, _ := v.(*models.Stats)
+	if stats == nil {
+		return nil, nil
 	}
-	broadcast <- statsTokenFrom
+	// Every caller gets a copy of its own, since the coalesced callers share the result
+	return copyStats(stats), nil
+}
 
-	data, err = s.repo.UpsertStats(ctx, event.TokenTo, event.UsdValue)
-	if err != nil {
-		return errors.Wrapf(err, "failed to upsert stats for tokenTo %s and usd value %v", event.TokenTo, event.UsdValue)
+// Service reading the same store without the cache, e.g. for the snapshots of the streams,
+// which must include every update up to their seq
+func (s *StatsService) Uncached() *StatsService {
+	return NewStatsService(s.repo, CacheConfig{})
+}
+
+// How long the stats of the window are cached, 0 when they aren't.
+// Clients and CDNs may cache the responses for as long
+func (s *StatsService) CacheTTL(window string) time.Duration {
+	layout, ok := windowLayouts[window]
+	if !ok || s.cacheCfg.BucketDivisor <= 0 {
+		return 0
 	}
-	statsTokenTo, err := json.Marshal(data)
+	return layout.bucketSize / time.Duration(s.cacheCfg.BucketDivisor)
+}
+
+// Get the stats of many keys like "stats:ETH:5min" at once, along with the errors of the keys that failed to read
+func (s *StatsService) GetStatsBatch(ctx context.Context, keys []string) (map[string]*models.Stats, map[string]error, error) {
+	stats, keyErrs, err := s.repo.GetStatsBatch(ctx, keys)
+	return stats, keyErrs, storeError(err)
+}
+
+// Get the stats of both directions of the base-quote pair in the window, read in a single round-trip
+func (s *StatsService) GetPairStats(ctx context.Context, base, quote, window string) (*models.PairStats, error) {
+	undirectedKey := fmt.Sprintf("stats:%s:%s", utils.BuildUndirectedKey(base, quote), window)
+	buyKey := fmt.Sprintf("stats:%s:%s", utils.BuildHyphenKey(quote, base), window)
+	sellKey := fmt.Sprintf("stats:%s:%s", utils.BuildHyphenKey(base, quote), window)
+	keys := []string{undirectedKey, buyKey, sellKey}
+
+	stats, keyErrs, err := s.repo.GetStatsBatch(ctx, keys)
 	if err != nil {
-		return errors.Wrapf(err, "failed to marshal stats data for tokenTo %s and usd value %v", event.TokenTo, event.UsdValue)
+		return nil, storeError(err)
+	}
+	for _, key := range keys {
+		if keyErrs[key] != nil {
+			return nil, storeError(errors.Wrapf(keyErrs[key], "failed to read window key %s", key))
+		}
+	}
+	if stats[undirectedKey].TxCount == 0 {
+		return nil, NewError(ErrNotFound, "no swaps of %s/%s in the %s window", base, quote, window)
 	}
-	broadcast <- statsTokenTo
+	return &models.PairStats{
+		Base:  base,
+		Quote: quote,
+		Stats: *stats[undirectedKey],
+		Buy:   *stats[buyKey],
+		Sell:  *stats[sellKey],
+	}, nil
+}
+
+// Get the per-bucket stats of a key like "stats:ETH:1h" to chart the window
+func (s *StatsService) GetSeries(ctx context.Context, key string) (*models.Series, error) {
+	series, err := s.repo.GetSeries(ctx, key)
+	return series, storeError(err)
+}
+
+// Get the stats of a token "ETH" or pair "ETH-BTC" key in the time range, at the finest retained granularity
+func (s *StatsService) GetRangeStats(ctx context.Context, key string, from, to time.Time) (*models.RangeStats, error) {
+	stats, err := s.repo.GetRangeStats(ctx, key, from, to)
+	return stats, storeError(err)
+}
 
+// Get up to limit top tokens or pairs of the window ranked by volume or tx count
+func (s *StatsService) GetLeaderboard(ctx context.Context, window, kind, by string, limit int) (*models.Leaderboard, error) {
+	board, err := s.repo.GetLeaderboard(ctx, window, kind, by, limit)
+	return board, storeError(err)
+}
+
+func (s *StatsService) LastUpdate(ctx context.Context) (time.Time, error) {
+	return s.repo.LastUpdate(ctx)
+}
+
+func (s *StatsService) Ping(ctx context.Context) error {
+	return s.repo.Ping(ctx)
+}
+
+// Aggregate the swap under its tokens and pair and publish the updated stats to the gateways
+func (s *StatsService) ProcessSwapEvent(ctx context.Context, event models.SwapEvent) error {
+	var updates []models.StatsUpdate
 	tokenPair := utils.BuildHyphenKey(event.TokenFrom, event.TokenTo)
-	data, err = s.repo.UpsertStats(ctx, tokenPair, event.UsdValue)
-	if err != nil {
-		return errors.Wrapf(err, "failed to upsert stats for token pair %s and usd value %v", tokenPair, event.UsdValue)
+	// Tokens are on the sold side of the swaps from them and on the bought side of the swaps to them,
+	// pairs have the amounts of both of their legs
+	pairAmounts := map[string]float64{event.TokenFrom: event.AmountFrom, event.TokenTo: event.AmountTo}
+	targets := []struct {
+		key   string
+		delta models.StatsDelta
+	}{
+		{event.TokenFrom, models.StatsDelta{UsdValue: event.UsdValue, Side: models.SideSold, Amounts: map[string]float64{event.TokenFrom: event.AmountFrom}}},
+		{event.TokenTo, models.StatsDelta{UsdValue: event.UsdValue, Side: models.SideBought, Amounts: map[string]float64{event.TokenTo: event.AmountTo}}},
+		{tokenPair, models.StatsDelta{UsdValue: event.UsdValue, Amounts: pairAmounts}},
+	}
+	for _, target := range targets {
+		key := target.key
+		data, err := s.upsertStats(ctx, key, target.delta)
+		if err != nil {
+			return err
+		}
+		for _, window := range models.Windows {
+			if stats, ok := data[window]; ok {
+				updates = append(updates, models.StatsUpdate{Key: key, Window: window, Stats: stats, TxHash: event.TxHash})
+			}
+		}
 	}
-	statsTokenPair, err := json.Marshal(data)
+
+	// The undirected aggregate of the pair isn't streamed, its updates are those of the directed pairs
+	undirectedDelta := models.StatsDelta{UsdValue: event.UsdValue, Amounts: pairAmounts}
+	if _, err := s.upsertStats(ctx, utils.BuildUndirectedKey(event.TokenFrom, event.TokenTo), undirectedDelta); err != nil {
+		return err
+	}
+	return s.publishUpdates(ctx, updates)
+}
+
+// Stream of the published updates starting with up to backlog most recent ones.
+// Reading errors are logged and retried, the channel is closed once the context is done
+func (s *StatsService) SubscribeUpdates(ctx context.Context, backlog int64) <-chan models.StatsUpdate {
+	ch := make(chan models.StatsUpdate, 1024)
+	go func() {
+		defer close(ch)
+
+		// Start right after the most recent update even when no backlog is requested,
+		// since reading after "$" repeatedly would miss the updates published between the reads
+		lastID := "$"
+		recent, id, err := s.repo.RecentUpdates(ctx, max(backlog, 1))
+		if err != nil {
+			log.Printf("failed to read recent updates: %v\n", err)
+		} else {
+			lastID = id
+			if backlog == 0 {
+				recent = nil
+			}
+		}
+		for _, update := range recent {
+			select {
+			case ch <- update:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for ctx.Err() == nil {
+			updates, id, err := s.repo.ReadUpdates(ctx, lastID, updatesBlockTimeout)
+			lastID = id
+			for _, update := range updates {
+				select {
+				case ch <- update:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil && ctx.Err() == nil {
+				log.Printf("failed to read updates: %v\n", err)
+				time.Sleep(time.Second)
+			}
+		}
+	}()
+	return ch
+}
+
+// Aggregate the swap's contribution to the key in a span of its own
+func (s *StatsService) upsertStats(ctx context.Context, key string, delta models.StatsDelta) (map[string]*models.Stats, error) {
+	ctx, span := tracer.Start(ctx, "stats.upsert", trace.WithAttributes(
+		attribute.String("stats.key", key),
+		attribute.Float64("stats.usd_value", delta.UsdValue),
+		attribute.String("stats.side", delta.Side),
+	))
+	defer span.End()
+
+	data, err := s.repo.UpsertStats(ctx, key, delta)
 	if err != nil {
-		return errors.Wrapf(err, "failed to marshal stats data for token pair %s and usd value %v", tokenPair, event.UsdValue)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to upsert stats")
+		return nil, errors.Wrapf(err, "failed to upsert stats for key %s and usd value %v", key, delta.UsdValue)
 	}
-	broadcast <- statsTokenPair
+	return data, nil
+}
+
+// Publish the updates to the web-socket gateways in a span of its own
+func (s *StatsService) publishUpdates(ctx context.Context, updates []models.StatsUpdate) error {
+	ctx, span := tracer.Start(ctx, "stats.publish", trace.WithAttributes(
+		attribute.Int("stats.updates", len(updates)),
+	))
+	defer span.End()
 
+	err := s.repo.PublishUpdates(ctx, updates)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to publish updates")
+		return errors.Wrap(err, "failed to publish stats updates")
+	}
 	return nil
 }
@@ -4,3 +4,10 @@ type RedisConfig struct {
 	Addr     string
 	Password string
 }
+
+// Read-through cache of the window stats. The stats of a window are cached for its bucket size divided by
+// BucketDivisor, e.g. 60 caches the 5min window of 1 minute buckets for a second and the 24h window for a minute.
+// Caching is disabled when it's 0
+type CacheConfig struct {
+	BucketDivisor int
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"consumer/internal/models"
+	"consumer/internal/tracing"
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Stream every stats update is published to, read by all web-socket gateways
+	updatesStreamKey = "stats:updates"
+	// Counter the sequence numbers of the updates are taken from
+	updatesSeqKey = "stats:updates:seq"
+	// Prefix of the keys holding the seq of the last update of every key and window
+	updatesLastSeqPrefix = "stats:updates:last:"
+	// Approximate number of updates kept in the stream
+	updatesStreamMaxLen = 10000
+)
+
+// Take the next sequence number and append the update to the stream atomically,
+// so that the stream order always matches the sequence order across all publishers.
+// The seq of the previous update of the same key and window is swapped for it and stored along,
+// so that clients receiving only some keys can still tell whether they missed an update of one.
+// The traceparent of the publishing span goes along, empty when tracing is disabled
+var publishScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+local prev = redis.call('GETSET', KEYS[3], seq) or 0
+redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[2], '*', 'seq', seq, 'prev_seq', prev, 'update', ARGV[1], 'traceparent', ARGV[3])
+return seq
+`)
+
+// Publish the updates to the stream in a single round-trip, their sequence numbers are assigned by Redis
+func (r *RedisStatsRepo) PublishUpdates(ctx context.Context, updates []models.StatsUpdate) error {
+	traceParent := tracing.TraceParent(ctx)
+	pipe := r.rdb.Pipeline()
+	for _, update := range updates {
+		data, err := json.Marshal(update)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal update of key %s", update.Key)
+		}
+		keys := []string{updatesSeqKey, updatesStreamKey, updatesLastSeqPrefix + update.Key + ":" + update.Window}
+		publishScript.Eval(ctx, pipe, keys, data, updatesStreamMaxLen, traceParent)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to publish %d updates", len(updates))
+	}
+	return nil
+}
+
+// Read the updates published after the stream entry with the given id, waiting up to block for new ones.
+// The special id "$" stands for the last entry. Returns the id of the last read entry to continue from
+func (r *RedisStatsRepo) ReadUpdates(
+	ctx context.Context,
+	afterID string,
+	block time.Duration,
+) ([]models.StatsUpdate, string, error) {
+	streams, err := r.rdb.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{updatesStreamKey, afterID},
+		Block:   block,
+		Count:   1000,
+	}).Result()
+	if err == redis.Nil {
+		return nil, afterID, nil
+	}
+	if err != nil {
+		return nil, afterID, errors.Wrapf(err, "failed to read stream %s after %s", updatesStreamKey, afterID)
+	}
+
+	var updates []models.StatsUpdate
+	lastID := afterID
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			lastID = msg.ID
+			update, err := parseUpdate(msg)
+			if err != nil {
+				return updates, lastID, err
+			}
+			updates = append(updates, update)
+		}
+	}
+	return updates, lastID, nil
+}
+
+// Up to count most recent updates in the publishing order along with the id of the last one,
+// so that a gateway can fill its replay buffer on start
+func (r *RedisStatsRepo) RecentUpdates(ctx context.Context, count int64) ([]models.StatsUpdate, string, error) {
+	msgs, err := r.rdb.XRevRangeN(ctx, updatesStreamKey, "+", "-", count).Result()
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to read the last %d entries of stream %s", count, updatesStreamKey)
+	}
+	if len(msgs) == 0 {
+		return nil, "0-0", nil
+	}
+
+	updates := make([]models.StatsUpdate, 0, len(msgs))
+	for i := len(msgs) - 1; i >= 0; i-- {
+		update, err := parseUpdate(msgs[i])
+		if err != nil {
+			return nil, "", err
+		}
+		updates = append(updates, update)
+	}
+	return updates, msgs[0].ID, nil
+}
+
+func parseUpdate(msg redis.XMessage) (models.StatsUpdate, error) {
+	var update models.StatsUpdate
+	data, _ := msg.Values["update"].(string)
+	if err := json.Unmarshal([]byte(data), &update); err != nil {
+		return update, errors.Wrapf(err, "failed to unmarshal update of stream entry %s", msg.ID)
+	}
+	seq, _ := msg.Values["seq"].(string)
+	parsed, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return update, errors.Wrapf(err, "failed to parse seq of stream entry %s", msg.ID)
+	}
+	update.Seq = parsed
+	update.TraceParent, _ = msg.Values["traceparent"].(string)
+	// Entries published before the previous seq was recorded have none
+	if prevSeq, ok := msg.Values["prev_seq"].(string); ok {
+		update.PrevSeq, err = strconv.ParseUint(prevSeq, 10, 64)
+		if err != nil {
+			return update, errors.Wrapf(err, "failed to parse prev_seq of stream entry %s", msg.ID)
+		}
+	}
+	return update, nil
+}
@@ -1,7 +1,9 @@
-package middleware
+package services
 
 import (
+	"maps"
 	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -19,6 +21,11 @@ var validTokens = map[string]bool{
 	"ETH":  true,
 }
 
+// All valid tokens in alphabetical order
+func Tokens() []string {
+	return slices.Sorted(maps.Keys(validTokens))
+}
+
 // Function to validate the period window
 func IsValidPeriod(period string) bool {
 	_, valid := validPeriodWindows[period]
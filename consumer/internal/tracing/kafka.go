@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+)
+
+// Carrier to read the trace context injected by the producer into Kafka message headers
+type kafkaHeadersCarrier struct {
+	msg *kafka.Message
+}
+
+func (c kafkaHeadersCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c kafkaHeadersCarrier) Set(key, value string) {
+	c.msg.Headers = append(c.msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c kafkaHeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+// Continue the trace started by the producer of the message
+func ExtractKafkaHeaders(ctx context.Context, msg *kafka.Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, kafkaHeadersCarrier{msg})
+}
@@ -0,0 +1,25 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header of the W3C trace context holding the trace and span ids
+const traceParentKey = "traceparent"
+
+// W3C traceparent of the span in the context, empty when there is none.
+// Carried along the stats updates in the Redis stream, so that their broadcasts can link back to the swap
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get(traceParentKey)
+}
+
+// Span context of the W3C traceparent, invalid when it's empty or malformed
+func SpanContextOf(traceParent string) trace.SpanContext {
+	carrier := propagation.MapCarrier{traceParentKey: traceParent}
+	return trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), carrier))
+}
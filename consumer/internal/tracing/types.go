@@ -0,0 +1,7 @@
+package tracing
+
+type Config struct {
+	Exporter     string // "none", "stdout" or "otlp"
+	OtlpEndpoint string // host:port of an OTLP/HTTP collector, e.g. "localhost:4318"
+	ServiceName  string
+}
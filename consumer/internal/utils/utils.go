@@ -1,6 +1,13 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 func BuildSemicolonKey(key, window string) string {
 	return fmt.Sprintf("%s:%s", key, window)
@@ -10,6 +17,103 @@ func BuildHyphenKey(t1, t2 string) string {
 	return fmt.Sprintf("%s-%s", t1, t2)
 }
 
+// Key of the undirected aggregate of a pair, its tokens in alphabetical order, e.g. "BTC/ETH" of both "ETH-BTC" and "BTC-ETH"
+func BuildUndirectedKey(t1, t2 string) string {
+	if t2 < t1 {
+		t1, t2 = t2, t1
+	}
+	return fmt.Sprintf("%s/%s", t1, t2)
+}
+
 func Contains(s, substr string) bool {
 	return len(s) >= len(substr) && s[len(s)-len(substr):] == substr
 }
+
+// Parse the duration from the environment variable, e.g. "30s", falling back to the default when unset or invalid
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid duration %q in %s, using %s\n", value, key, fallback)
+		return fallback
+	}
+	return d
+}
+
+// Parse the duration like GetEnvDuration, also falling back to the default when it isn't positive,
+// e.g. for the intervals of tickers, which panic on those
+func GetEnvPositiveDuration(key string, fallback time.Duration) time.Duration {
+	d := GetEnvDuration(key, fallback)
+	if d <= 0 {
+		log.Printf("non-positive duration %s in %s, using %s\n", d, key, fallback)
+		return fallback
+	}
+	return d
+}
+
+// Parse the integer from the environment variable, falling back to the default when unset or invalid
+func GetEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("invalid integer %q in %s, using %d\n", value, key, fallback)
+		return fallback
+	}
+	return i
+}
+
+// Parse the integer like GetEnvInt, also falling back to the default when it isn't positive,
+// e.g. for the sizes of queues, which would overflow with every message otherwise
+func GetEnvPositiveInt(key string, fallback int) int {
+	i := GetEnvInt(key, fallback)
+	if i <= 0 {
+		log.Printf("non-positive integer %d in %s, using %d\n", i, key, fallback)
+		return fallback
+	}
+	return i
+}
+
+// Parse the float from the environment variable, falling back to the default when unset or invalid
+func GetEnvFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("invalid float %q in %s, using %v\n", value, key, fallback)
+		return fallback
+	}
+	return f
+}
+
+// Parse the boolean from the environment variable, e.g. "true" or "0", falling back to the default when unset or invalid
+func GetEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("invalid boolean %q in %s, using %t\n", value, key, fallback)
+		return fallback
+	}
+	return b
+}
+
+// Split the comma-separated environment variable into trimmed non-empty items, nil when unset
+func GetEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
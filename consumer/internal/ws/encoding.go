@@ -0,0 +1,110 @@
+package ws
+
+import (
+	"bytes"
+	"consumer/internal/feed"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Encodings of the messages sent to clients, negotiated on connect
+const (
+	EncodingJSON     = "json"     // text frames, the default
+	EncodingMsgpack  = "msgpack"  // binary frames with the same field names as JSON
+	EncodingProtobuf = "protobuf" // binary frames of stats.v1.StreamMessage
+)
+
+// Prefix of the subprotocols selecting the encodings, e.g. Sec-WebSocket-Protocol: stats.v1.msgpack
+const subprotocolPrefix = "stats.v1."
+
+type encoding struct {
+	index       int // position in encodings, used to cache the encoded messages
+	name        string
+	messageType int
+	marshal     func(feed.Message) ([]byte, error)
+}
+
+var encodings = [...]*encoding{
+	{0, EncodingJSON, websocket.TextMessage, marshalJSON},
+	{1, EncodingMsgpack, websocket.BinaryMessage, marshalMsgpack},
+	{2, EncodingProtobuf, websocket.BinaryMessage, marshalProtobuf},
+}
+
+func findEncoding(name string) *encoding {
+	for _, enc := range encodings {
+		if enc.name == name {
+			return enc
+		}
+	}
+	return nil
+}
+
+// Subprotocols of all encodings in the order of preference
+func subprotocols() []string {
+	names := make([]string, 0, len(encodings))
+	for _, enc := range encodings {
+		names = append(names, subprotocolPrefix+enc.name)
+	}
+	return names
+}
+
+// Encode the message and prepare its frames, so that they are compressed at most once for any number of clients
+func (e *encoding) prepare(message feed.Message) (*websocket.PreparedMessage, error) {
+	data, err := e.marshal(message)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode %s message as %s", message.Type, e.name)
+	}
+	return websocket.NewPreparedMessage(e.messageType, data)
+}
+
+// Broadcast update shared by all clients, encoded at most once per encoding
+type update struct {
+	feed.Entry
+	once     [len(encodings)]sync.Once
+	prepared [len(encodings)]*websocket.PreparedMessage
+}
+
+func newUpdate(entry feed.Entry) *update {
+	return &update{Entry: entry}
+}
+
+// Frames of the update in the encoding, nil if it can't be encoded
+func (u *update) prepare(enc *encoding) *websocket.PreparedMessage {
+	u.once[enc.index].Do(func() {
+		var err error
+		if enc.name == EncodingJSON {
+			// The hub has encoded the update as JSON already
+			u.prepared[enc.index], err = websocket.NewPreparedMessage(enc.messageType, u.Message)
+		} else {
+			u.prepared[enc.index], err = enc.prepare(u.Envelope)
+		}
+		if err != nil {
+			log.Printf("Error preparing update of key %s: %v", u.Update.Key, err)
+		}
+	})
+	return u.prepared[enc.index]
+}
+
+func marshalJSON(message feed.Message) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+func marshalMsgpack(message feed.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(message); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalProtobuf(message feed.Message) ([]byte, error) {
+	return proto.Marshal(message.Proto())
+}
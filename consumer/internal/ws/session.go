@@ -0,0 +1,339 @@
+package ws
+
+import (
+	"consumer/internal/auth"
+	"consumer/internal/feed"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// Deadline for sending the close frame to an evicted client
+const closeTimeout = time.Second
+
+// State of a single connected client.
+// All writes to the connection happen in the writeLoop goroutine,
+// others only enqueue messages, so a slow client never blocks the rest
+type session struct {
+	id          uint64
+	conn        *websocket.Conn
+	principal   *auth.Principal // nil when authentication is disabled
+	enc         *encoding
+	connectedAt time.Time
+	lastPong    atomic.Int64 // unix nanoseconds, 0 until the first pong
+	send        chan *websocket.PreparedMessage
+	done        chan struct{}
+	closeOnce   sync.Once
+	closeCode   int // close frame sent by the writeLoop once done is closed, none when 0
+	closeReason string
+	mu          sync.Mutex
+	subs        feed.Subscriptions
+	rates       map[feed.Subscription]float64 // max updates per second of the rate-capped subscriptions
+	pending     map[feed.Subscription]*update
+	nextSend    map[feed.Subscription]time.Time // earliest time the next update of a key and window can be sent
+	conflated   atomic.Uint64                   // updates replaced by a newer one before they were sent
+	holding     bool                            // updates are held back while a snapshot or replay is loading
+	held        []*update                       // updates to send right after the snapshot or replay
+}
+
+func newSession(id uint64, conn *websocket.Conn, principal *auth.Principal, enc *encoding, queueSize int) *session {
+	return &session{
+		id:          id,
+		conn:        conn,
+		principal:   principal,
+		enc:         enc,
+		connectedAt: time.Now(),
+		send:        make(chan *websocket.PreparedMessage, queueSize),
+		done:        make(chan struct{}),
+		subs:        make(feed.Subscriptions),
+		rates:       make(map[feed.Subscription]float64),
+		pending:     make(map[feed.Subscription]*update),
+		nextSend:    make(map[feed.Subscription]time.Time),
+	}
+}
+
+// Consider the client dead unless it sends something, at least a pong, within the timeout
+func (s *session) keepAlive(pongTimeout time.Duration) {
+	s.conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	s.conn.SetPongHandler(func(string) error {
+		s.lastPong.Store(time.Now().UnixNano())
+		return s.conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+}
+
+// Write the queued messages and periodic pings to the connection until the session is closed,
+// then send the close frame and close the connection, which also ends the read loop of the handler
+func (s *session) writeLoop(writeTimeout, pingInterval time.Duration) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	defer s.closeConn()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+			if err != nil {
+				if !s.closed() {
+					log.Printf("Error pinging client %s: %v", s.conn.RemoteAddr(), err)
+				}
+				s.close(0, "")
+				return
+			}
+		case message := <-s.send:
+			s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := s.conn.WritePreparedMessage(message); err != nil {
+				if !s.closed() {
+					log.Printf("Error writing to client %s: %v", s.conn.RemoteAddr(), err)
+				}
+				s.close(0, "")
+				return
+			}
+		}
+	}
+}
+
+// Queue the message without blocking, nil messages that failed to encode are skipped.
+// The client is evicted when its queue is full, since it can't keep up with the updates
+func (s *session) enqueue(message *websocket.PreparedMessage) bool {
+	if s.closed() {
+		return false
+	}
+	if message == nil {
+		return true
+	}
+
+	select {
+	case s.send <- message:
+		return true
+	default:
+		log.Printf("Evicting slow client %s: send queue of %d messages is full", s.conn.RemoteAddr(), cap(s.send))
+		s.close(websocket.ClosePolicyViolation, "send queue overflow, client is too slow")
+		return false
+	}
+}
+
+// Encode the message in the encoding of the client and queue it
+func (s *session) enqueueMessage(message feed.Message) error {
+	prepared, err := s.enc.prepare(message)
+	if err != nil {
+		return err
+	}
+	if !s.enqueue(prepared) {
+		return errors.New("session is closed")
+	}
+	return nil
+}
+
+func (s *session) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop the writer, which then closes the connection with a close frame of the code and reason unless the code is 0.
+// Never writes itself, since it's called by the hub's broadcast while the writer may be stuck on a slow client
+func (s *session) close(code int, reason string) {
+	s.closeOnce.Do(func() {
+		s.closeCode, s.closeReason = code, reason
+		close(s.done)
+	})
+}
+
+// Send the close frame recorded by close, if any, and close the connection. Called by the writeLoop only
+func (s *session) closeConn() {
+	s.close(0, "")
+	if s.closeCode != 0 {
+		message := websocket.FormatCloseMessage(s.closeCode, s.closeReason)
+		s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(closeTimeout))
+	}
+	s.conn.Close()
+}
+
+func (s *session) info() ClientInfo {
+	now := time.Now()
+	info := ClientInfo{
+		ID:             s.id,
+		RemoteAddr:     s.conn.RemoteAddr().String(),
+		Principal:      s.principal,
+		ConnectedAt:    s.connectedAt.UTC(),
+		AgeSeconds:     now.Sub(s.connectedAt).Seconds(),
+		Encoding:       s.enc.name,
+		QueuedMessages: len(s.send),
+		Conflated:      s.conflated.Load(),
+	}
+	if ns := s.lastPong.Load(); ns != 0 {
+		lastPong := time.Unix(0, ns).UTC()
+		sinceLastPong := now.Sub(lastPong).Seconds()
+		info.LastPongAt = &lastPong
+		info.SinceLastPongSeconds = &sinceLastPong
+	}
+
+	s.mu.Lock()
+	info.Subscriptions = s.subs.List()
+	s.mu.Unlock()
+	return info
+}
+
+// Queue the encoded update if the client is subscribed to its key and window.
+// While a snapshot is loading the update is held back, so that it never precedes the snapshot
+func (s *session) deliver(u *update) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.subs.Matches(u.Update.Key, u.Update.Window) {
+		return
+	}
+
+	if s.holding {
+		s.held = append(s.held, u)
+		if len(s.held) > cap(s.send) {
+			log.Printf("Evicting slow client %s: %d updates are held back by a snapshot", s.conn.RemoteAddr(), len(s.held))
+			s.close(websocket.ClosePolicyViolation, "send queue overflow, client is too slow")
+		}
+		return
+	}
+	s.push(u, time.Now())
+}
+
+// Queue the update unless its key and window are rate-capped and the last update went out too recently.
+// Then the update waits for the flush instead, replacing the one already waiting
+func (s *session) push(u *update, now time.Time) {
+	interval := s.interval(u.Update.Key, u.Update.Window)
+	if interval == 0 {
+		s.enqueue(u.prepare(s.enc))
+		return
+	}
+
+	stream := feed.Subscription{Key: u.Update.Key, Window: u.Update.Window}
+	if _, ok := s.pending[stream]; ok {
+		s.pending[stream] = u
+		s.conflated.Add(1)
+		return
+	}
+	if now.Before(s.nextSend[stream]) {
+		s.pending[stream] = u
+		return
+	}
+	s.nextSend[stream] = now.Add(interval)
+	s.enqueue(u.prepare(s.enc))
+}
+
+// Queue the waiting updates that are due
+func (s *session) flush(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for stream, u := range s.pending {
+		if now.Before(s.nextSend[stream]) {
+			continue
+		}
+		delete(s.pending, stream)
+		// The subscription may be gone since the update started waiting
+		if !s.subs.Matches(stream.Key, stream.Window) {
+			continue
+		}
+		s.nextSend[stream] = now.Add(s.interval(stream.Key, stream.Window))
+		if !s.enqueue(u.prepare(s.enc)) {
+			return
+		}
+	}
+}
+
+// Minimum time between the updates of the key in the window, 0 when they aren't rate-capped.
+// When several subscriptions cover the key the least restrictive one applies
+func (s *session) interval(key, window string) time.Duration {
+	var maxRate float64
+	for _, sub := range s.subs.Matching(key, window) {
+		rate := s.rates[sub]
+		if rate == 0 {
+			return 0
+		}
+		maxRate = max(maxRate, rate)
+	}
+	if maxRate == 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / maxRate)
+}
+
+// Queue the snapshot or replayed messages followed by the updates held back while they were loading.
+// Held updates already covered by the replay, i.e. up to the seq, are skipped
+func (s *session) release(messages []*websocket.PreparedMessage, seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer func() {
+		s.holding = false
+		s.held = nil
+	}()
+	for _, message := range messages {
+		if !s.enqueue(message) {
+			return
+		}
+	}
+	now := time.Now()
+	for _, u := range s.held {
+		if u.Update.Seq <= seq {
+			continue
+		}
+		if s.closed() {
+			return
+		}
+		s.push(u, now)
+	}
+}
+
+// Apply the control request and build the response to it.
+// Subscribing starts holding the updates back until the snapshot of the returned subscriptions is released
+func (s *session) handleRequest(req Request) (feed.Message, []feed.Subscription) {
+	var subs []feed.Subscription
+	var err error
+	switch req.Action {
+	case ActionSubscribe, ActionUnsubscribe:
+		subs, err = feed.BuildSubscriptions(req.Keys, req.Windows)
+		if err == nil && req.MaxRate < 0 {
+			err = errors.New("max_rate must not be negative")
+		}
+	case ActionList:
+	default:
+		err = errors.Errorf("unknown action %q", req.Action)
+	}
+	if err != nil {
+		return feed.NewErrorMessage(req.ID, req.Action, err.Error()), nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch req.Action {
+	case ActionSubscribe:
+		s.subs.Add(subs)
+		for _, sub := range subs {
+			if req.MaxRate > 0 {
+				s.rates[sub] = req.MaxRate
+			} else {
+				delete(s.rates, sub)
+			}
+		}
+		s.holding = true
+	case ActionUnsubscribe:
+		s.subs.Remove(subs)
+		for _, sub := range subs {
+			delete(s.rates, sub)
+		}
+		subs = nil
+	}
+	ack := feed.NewMessage(feed.TypeAck)
+	ack.ID = req.ID
+	ack.Action = req.Action
+	ack.Subscriptions = s.subs.List()
+	return ack, subs
+}
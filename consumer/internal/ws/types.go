@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"consumer/internal/auth"
+	"consumer/internal/feed"
+	"time"
+)
+
+// Control actions clients can send over the connection
+const (
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+	ActionList        = "list"
+)
+
+// Control request sent by a client, e.g.
+// {"id": "1", "action": "subscribe", "keys": ["ETH", "BTC-*"], "windows": ["5min"]}
+// Omitted windows mean all period windows.
+// A reconnecting client can pass the seq of the last update it got in last_seq
+// to get the missed updates replayed instead of a snapshot.
+// With max_rate the updates of every key and window of the subscription are capped to that many per second,
+// updates arriving faster are conflated so that only the latest one is sent
+type Request struct {
+	ID      string   `json:"id,omitempty"`
+	Action  string   `json:"action"`
+	Keys    []string `json:"keys,omitempty"`
+	Windows []string `json:"windows,omitempty"`
+	LastSeq *uint64  `json:"last_seq,omitempty"`
+	MaxRate float64  `json:"max_rate,omitempty"`
+}
+
+type Config struct {
+	SendQueueSize int           // messages buffered per client, the client is evicted when its queue overflows
+	WriteTimeout  time.Duration // deadline for writing a single message to the client
+	PingInterval  time.Duration // how often clients are pinged
+	PongTimeout   time.Duration // clients that stay silent for that long are considered dead, must exceed PingInterval
+	Compression   bool          // negotiate permessage-deflate with the clients supporting it
+
+	Authenticator  auth.Authenticator // verifies the credentials of connecting clients, anyone can connect when nil
+	AllowedOrigins []string           // origins browsers can connect from, any origin when empty or "*"
+	MaxConnsPerKey int                // connections a single API key or token subject can hold open, unlimited when 0
+}
+
+// Connected client as reported by the debug listing
+type ClientInfo struct {
+	ID                   uint64              `json:"id"`
+	RemoteAddr           string              `json:"remote_addr"`
+	Principal            *auth.Principal     `json:"principal"`
+	Encoding             string              `json:"encoding"`
+	ConnectedAt          time.Time           `json:"connected_at"`
+	AgeSeconds           float64             `json:"age_seconds"`
+	LastPongAt           *time.Time          `json:"last_pong_at"`
+	SinceLastPongSeconds *float64            `json:"since_last_pong_seconds"`
+	QueuedMessages       int                 `json:"queued_messages"`
+	Conflated            uint64              `json:"conflated_updates"`
+	Subscriptions        []feed.Subscription `json:"subscriptions"`
+}
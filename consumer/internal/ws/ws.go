@@ -1,87 +1,348 @@
 package ws
 
 import (
+	"consumer/internal/auth"
+	"consumer/internal/feed"
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// How often the conflated updates of the rate-capped subscriptions are sent
+const flushInterval = 50 * time.Millisecond
+
 type Client struct {
-	upgrader  websocket.Upgrader
-	mu        *sync.Mutex
-	clients   map[*websocket.Conn]bool
-	broadcast chan []byte
+	cfg         Config
+	stats       feed.StatsReader
+	upgrader    websocket.Upgrader
+	mu          *sync.Mutex
+	clients     map[*websocket.Conn]*session
+	connsPerKey map[string]int // open connections per principal
+	lastID      atomic.Uint64
+	conflated   atomic.Uint64 // conflated updates of the disconnected clients
+	hub         *feed.Hub
 }
 
-func New(broadcast chan []byte) *Client {
+// The client starts delivering the updates broadcast by the hub right away
+func New(hub *feed.Hub, stats feed.StatsReader, cfg Config) *Client {
+	if cfg.PongTimeout <= cfg.PingInterval {
+		log.Printf("pong timeout %s must exceed the ping interval %s, using %s", cfg.PongTimeout, cfg.PingInterval, 2*cfg.PingInterval)
+		cfg.PongTimeout = 2 * cfg.PingInterval
+	}
+
+	if cfg.Authenticator == nil {
+		log.Println("WebSocket authentication is disabled, anyone can connect")
+	}
+
 	upgrader := websocket.Upgrader{
+		// The origin is checked by the handler before the upgrade
 		CheckOrigin: func(r *http.Request) bool {
 			return true
 		},
+		Subprotocols:      subprotocols(),
+		EnableCompression: cfg.Compression,
 	}
-	return &Client{
-		upgrader:  upgrader,
-		mu:        &sync.Mutex{},
-		clients:   make(map[*websocket.Conn]bool),
-		broadcast: broadcast,
+	c := &Client{
+		cfg:         cfg,
+		stats:       stats,
+		upgrader:    upgrader,
+		mu:          &sync.Mutex{},
+		clients:     make(map[*websocket.Conn]*session),
+		connsPerKey: make(map[string]int),
+		hub:         hub,
 	}
+	hub.Listen(c.broadcast)
+	go c.flushLoop()
+	return c
 }
 
+// Clients can subscribe right on connect with the query parameters
+// "keys" and "windows" holding comma-separated lists, e.g. /ws?keys=ETH,BTC-*&windows=5min,
+// and optionally cap the rate of the updates with "max_rate", e.g. /ws?keys=*-*&max_rate=2.
+// Reconnecting clients add "last_seq" to resume the stream, e.g. /ws?keys=ETH&last_seq=42.
+// The encoding is negotiated with the subprotocol, e.g. "stats.v1.msgpack", or with the "encoding" parameter,
+// messages are JSON when neither is given.
+// Connections are rejected before the upgrade with 403 for an origin outside the allowlist,
+// 401 for missing or invalid credentials and 429 when the key holds too many connections
 func (c *Client) Handler(w http.ResponseWriter, r *http.Request) {
+	if !c.isAllowedOrigin(r.Header.Get("Origin")) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	var principal *auth.Principal
+	if c.cfg.Authenticator != nil {
+		var err error
+		principal, err = c.cfg.Authenticator.Authenticate(r)
+		if err != nil {
+			log.Printf("Rejecting client %s: %v", r.RemoteAddr, err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ws"`)
+			http.Error(w, auth.ErrorMessage(err), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	query := r.URL.Query()
+	enc := encodings[0]
+	if name := query.Get("encoding"); name != "" {
+		if enc = findEncoding(name); enc == nil {
+			http.Error(w, "invalid encoding", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var initial *Request
+	if keys := query.Get("keys"); keys != "" {
+		initial = &Request{Action: ActionSubscribe, Keys: strings.Split(keys, ",")}
+		if windows := query.Get("windows"); windows != "" {
+			initial.Windows = strings.Split(windows, ",")
+		}
+		if _, err := feed.BuildSubscriptions(initial.Keys, initial.Windows); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if lastSeq := query.Get("last_seq"); lastSeq != "" {
+			seq, err := strconv.ParseUint(lastSeq, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid last_seq", http.StatusBadRequest)
+				return
+			}
+			initial.LastSeq = &seq
+		}
+		if maxRate := query.Get("max_rate"); maxRate != "" {
+			rate, err := strconv.ParseFloat(maxRate, 64)
+			if err != nil || rate < 0 {
+				http.Error(w, "invalid max_rate", http.StatusBadRequest)
+				return
+			}
+			initial.MaxRate = rate
+		}
+	}
+
+	if !c.acquireConn(principal) {
+		log.Printf("Rejecting client %s: %s holds %d connections already", r.RemoteAddr, principal, c.cfg.MaxConnsPerKey)
+		http.Error(w, "too many connections", http.StatusTooManyRequests)
+		return
+	}
+	defer c.releaseConn(principal)
+
 	conn, err := c.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Error upgrading: %v", err)
 		return
 	}
+
+	// The subprotocol takes precedence over the query parameter
+	if subprotocol := conn.Subprotocol(); subprotocol != "" {
+		enc = findEncoding(strings.TrimPrefix(subprotocol, subprotocolPrefix))
+	}
+
+	s := newSession(c.lastID.Add(1), conn, principal, enc, c.cfg.SendQueueSize)
+	c.mu.Lock()
+	c.clients[conn] = s
+	total := len(c.clients)
+	c.mu.Unlock()
+
 	defer func() {
+		s.close(0, "")
 		c.mu.Lock()
 		delete(c.clients, conn)
+		total := len(c.clients)
 		c.mu.Unlock()
-		conn.Close()
+		c.conflated.Add(s.conflated.Load())
+		log.Printf("Client disconnected. Total clients: %d", total)
 	}()
 
+	log.Printf("Client connected. Total clients: %d", total)
+	s.keepAlive(c.cfg.PongTimeout)
+	go s.writeLoop(c.cfg.WriteTimeout, c.cfg.PingInterval)
+	if initial != nil {
+		if err := c.handleRequest(r.Context(), s, *initial); err != nil {
+			log.Printf("Error responding to client: %v", err)
+			return
+		}
+	}
+	c.handleIncomingMessages(r.Context(), s)
+}
+
+// Browsers always send the origin of the page, other clients usually send none and are let through
+func (c *Client) isAllowedOrigin(origin string) bool {
+	if origin == "" || len(c.cfg.AllowedOrigins) == 0 {
+		return true
+	}
+	for _, allowed := range c.cfg.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count the connection of the principal, false when it reached the limit.
+// Connections are only counted when authentication is enabled
+func (c *Client) acquireConn(principal *auth.Principal) bool {
+	if principal == nil || c.cfg.MaxConnsPerKey <= 0 {
+		return true
+	}
+
 	c.mu.Lock()
-	c.clients[conn] = true
-	c.mu.Unlock()
+	defer c.mu.Unlock()
+	if c.connsPerKey[principal.String()] >= c.cfg.MaxConnsPerKey {
+		return false
+	}
+	c.connsPerKey[principal.String()]++
+	return true
+}
+
+func (c *Client) releaseConn(principal *auth.Principal) {
+	if principal == nil || c.cfg.MaxConnsPerKey <= 0 {
+		return
+	}
 
-	log.Printf("Client connected. Total clients: %d", len(c.clients))
-	c.drainIncomingMessages(conn)
-	log.Printf("Client disconnected. Total clients: %d", len(c.clients)-1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connsPerKey[principal.String()]--
+	if c.connsPerKey[principal.String()] <= 0 {
+		delete(c.connsPerKey, principal.String())
+	}
 }
 
-func (c *Client) HandleBroadcasting() {
-	for {
-		message := <-c.broadcast
-		c.mu.Lock()
+// Queue the update only for the clients subscribed to its key and window.
+// The update is encoded once per encoding the clients use, not once per client
+func (c *Client) broadcast(entry feed.Entry) {
+	u := newUpdate(entry)
+	for _, s := range c.sessions() {
+		s.deliver(u)
+	}
+}
 
-		var toRemove []*websocket.Conn
-		for client := range c.clients {
-			err := client.WriteMessage(websocket.TextMessage, message)
-			if err != nil {
-				log.Printf("Error writing to client: %v", err)
-				toRemove = append(toRemove, client)
-			}
-		}
+// Send the conflated updates of all clients as they become due
+func (c *Client) flushLoop() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
 
-		for _, client := range toRemove {
-			client.Close()
-			delete(c.clients, client)
+	for now := range ticker.C {
+		for _, s := range c.sessions() {
+			s.flush(now)
 		}
+	}
+}
 
-		c.mu.Unlock()
+// Copy of the connected sessions.
+// The lock is held just to copy them, so connects and disconnects never wait for the fan-out
+func (c *Client) sessions() []*session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sessions := make([]*session, 0, len(c.clients))
+	for _, s := range c.clients {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// List the connected clients with their age, last pong time and subscriptions,
+// along with the number of updates conflated for all clients since the start
+func (c *Client) DebugHandler(w http.ResponseWriter, r *http.Request) {
+	conflated := c.conflated.Load()
+	sessions := c.sessions()
+	clients := make([]ClientInfo, 0, len(sessions))
+	for _, s := range sessions {
+		info := s.info()
+		conflated += info.Conflated
+		clients = append(clients, info)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(map[string]any{"total": len(clients), "conflated_updates": conflated, "clients": clients})
+	if err != nil {
+		log.Printf("Error writing clients listing: %v", err)
 	}
 }
 
-func (c *Client) drainIncomingMessages(conn *websocket.Conn) {
+// Read the control requests of the client until it disconnects or gets evicted
+func (c *Client) handleIncomingMessages(ctx context.Context, s *session) {
 	for {
-		_, _, err := conn.ReadMessage()
+		_, message, err := s.conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("Reaping dead client %s: no pong for %s", s.conn.RemoteAddr(), c.cfg.PongTimeout)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("Unexpected WebSocket error: %v", err)
 			}
 			break
 		}
+		// Any message proves the client is alive as well as a pong
+		s.conn.SetReadDeadline(time.Now().Add(c.cfg.PongTimeout))
+
+		var req Request
+		if err := json.Unmarshal(message, &req); err != nil {
+			err = s.enqueueMessage(feed.NewErrorMessage("", "", "invalid request: "+err.Error()))
+			if err != nil {
+				log.Printf("Error responding to client: %v", err)
+				break
+			}
+			continue
+		}
+
+		if err := c.handleRequest(ctx, s, req); err != nil {
+			log.Printf("Error responding to client: %v", err)
+			break
+		}
+	}
+}
+
+// Apply the control request and acknowledge it.
+// New subscriptions are followed by the snapshot of their stats,
+// or by the replay of the missed updates when the client resumes from a seq
+func (c *Client) handleRequest(ctx context.Context, s *session, req Request) error {
+	resp, subs := s.handleRequest(req)
+	if err := s.enqueueMessage(resp); err != nil {
+		return err
+	}
+	if resp.Type != feed.TypeAck || req.Action != ActionSubscribe {
+		return nil
+	}
+
+	initial, err := feed.LoadInitial(ctx, c.hub, c.stats, subs, req.LastSeq)
+	if err != nil {
+		log.Printf("Error loading snapshot: %v", err)
+		initial = &feed.Initial{Messages: []feed.Message{feed.NewErrorMessage(req.ID, ActionSubscribe, "failed to load snapshot")}}
+	}
+	if initial.Resumed {
+		messages := make([]*websocket.PreparedMessage, 0, len(initial.Replay))
+		for _, entry := range initial.Replay {
+			messages = append(messages, newUpdate(entry).prepare(s.enc))
+		}
+		s.release(messages, initial.Seq)
+		return nil
+	}
+
+	messages := make([]*websocket.PreparedMessage, 0, len(initial.Messages))
+	for _, message := range initial.Messages {
+		if message.Type == feed.TypeSnapshot {
+			message.ID = req.ID
+		}
+		prepared, err := s.enc.prepare(message)
+		if err != nil {
+			log.Printf("Error preparing message: %v", err)
+			continue
+		}
+		messages = append(messages, prepared)
 	}
+	s.release(messages, 0)
+	return nil
 }
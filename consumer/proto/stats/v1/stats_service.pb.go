@@ -0,0 +1,438 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: stats/v1/stats_service.proto
+
+package statsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`       // token "ETH" or pair "BTC-USDT"
+	Window        string                 `protobuf:"bytes,2,opt,name=window,proto3" json:"window,omitempty"` // "5min", "1h" or "24h"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_stats_v1_stats_service_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stats_v1_stats_service_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_stats_v1_stats_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetStatsRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *GetStatsRequest) GetWindow() string {
+	if x != nil {
+		return x.Window
+	}
+	return ""
+}
+
+type GetStatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stats         *Stats                 `protobuf:"bytes,1,opt,name=stats,proto3" json:"stats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsResponse) Reset() {
+	*x = GetStatsResponse{}
+	mi := &file_stats_v1_stats_service_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsResponse) ProtoMessage() {}
+
+func (x *GetStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_stats_v1_stats_service_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetStatsResponse) Descriptor() ([]byte, []int) {
+	return file_stats_v1_stats_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetStatsResponse) GetStats() *Stats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+type BatchGetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Keys          []string               `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	Windows       []string               `protobuf:"bytes,2,rep,name=windows,proto3" json:"windows,omitempty"` // all period windows when empty
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetStatsRequest) Reset() {
+	*x = BatchGetStatsRequest{}
+	mi := &file_stats_v1_stats_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetStatsRequest) ProtoMessage() {}
+
+func (x *BatchGetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stats_v1_stats_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetStatsRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_stats_v1_stats_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BatchGetStatsRequest) GetKeys() []string {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+func (x *BatchGetStatsRequest) GetWindows() []string {
+	if x != nil {
+		return x.Windows
+	}
+	return nil
+}
+
+type BatchGetStatsResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Window        string                 `protobuf:"bytes,2,opt,name=window,proto3" json:"window,omitempty"`
+	Stats         *Stats                 `protobuf:"bytes,3,opt,name=stats,proto3" json:"stats,omitempty"` // unset when the key failed
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetStatsResult) Reset() {
+	*x = BatchGetStatsResult{}
+	mi := &file_stats_v1_stats_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetStatsResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetStatsResult) ProtoMessage() {}
+
+func (x *BatchGetStatsResult) ProtoReflect() protoreflect.Message {
+	mi := &file_stats_v1_stats_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetStatsResult.ProtoReflect.Descriptor instead.
+func (*BatchGetStatsResult) Descriptor() ([]byte, []int) {
+	return file_stats_v1_stats_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchGetStatsResult) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *BatchGetStatsResult) GetWindow() string {
+	if x != nil {
+		return x.Window
+	}
+	return ""
+}
+
+func (x *BatchGetStatsResult) GetStats() *Stats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *BatchGetStatsResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type BatchGetStatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*BatchGetStatsResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetStatsResponse) Reset() {
+	*x = BatchGetStatsResponse{}
+	mi := &file_stats_v1_stats_service_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetStatsResponse) ProtoMessage() {}
+
+func (x *BatchGetStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_stats_v1_stats_service_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetStatsResponse.ProtoReflect.Descriptor instead.
+func (*BatchGetStatsResponse) Descriptor() ([]byte, []int) {
+	return file_stats_v1_stats_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchGetStatsResponse) GetResults() []*BatchGetStatsResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type SubscribeStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Keys          []string               `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`                             // tokens, pairs or patterns with "*" for any token, all keys when empty
+	Windows       []string               `protobuf:"bytes,2,rep,name=windows,proto3" json:"windows,omitempty"`                       // all period windows when empty
+	LastSeq       *uint64                `protobuf:"varint,3,opt,name=last_seq,json=lastSeq,proto3,oneof" json:"last_seq,omitempty"` // seq of the last update received, to resume after a reconnect
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeStatsRequest) Reset() {
+	*x = SubscribeStatsRequest{}
+	mi := &file_stats_v1_stats_service_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeStatsRequest) ProtoMessage() {}
+
+func (x *SubscribeStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stats_v1_stats_service_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeStatsRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeStatsRequest) Descriptor() ([]byte, []int) {
+	return file_stats_v1_stats_service_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SubscribeStatsRequest) GetKeys() []string {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+func (x *SubscribeStatsRequest) GetWindows() []string {
+	if x != nil {
+		return x.Windows
+	}
+	return nil
+}
+
+func (x *SubscribeStatsRequest) GetLastSeq() uint64 {
+	if x != nil && x.LastSeq != nil {
+		return *x.LastSeq
+	}
+	return 0
+}
+
+var File_stats_v1_stats_service_proto protoreflect.FileDescriptor
+
+const file_stats_v1_stats_service_proto_rawDesc = "" +
+	"\n" +
+	"\x1cstats/v1/stats_service.proto\x12\bstats.v1\x1a\x15stats/v1/stream.proto\";\n" +
+	"\x0fGetStatsRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x16\n" +
+	"\x06window\x18\x02 \x01(\tR\x06window\"9\n" +
+	"\x10GetStatsResponse\x12%\n" +
+	"\x05stats\x18\x01 \x01(\v2\x0f.stats.v1.StatsR\x05stats\"D\n" +
+	"\x14BatchGetStatsRequest\x12\x12\n" +
+	"\x04keys\x18\x01 \x03(\tR\x04keys\x12\x18\n" +
+	"\awindows\x18\x02 \x03(\tR\awindows\"|\n" +
+	"\x13BatchGetStatsResult\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x16\n" +
+	"\x06window\x18\x02 \x01(\tR\x06window\x12%\n" +
+	"\x05stats\x18\x03 \x01(\v2\x0f.stats.v1.StatsR\x05stats\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"P\n" +
+	"\x15BatchGetStatsResponse\x127\n" +
+	"\aresults\x18\x01 \x03(\v2\x1d.stats.v1.BatchGetStatsResultR\aresults\"r\n" +
+	"\x15SubscribeStatsRequest\x12\x12\n" +
+	"\x04keys\x18\x01 \x03(\tR\x04keys\x12\x18\n" +
+	"\awindows\x18\x02 \x03(\tR\awindows\x12\x1e\n" +
+	"\blast_seq\x18\x03 \x01(\x04H\x00R\alastSeq\x88\x01\x01B\v\n" +
+	"\t_last_seq2\xf1\x01\n" +
+	"\fStatsService\x12A\n" +
+	"\bGetStats\x12\x19.stats.v1.GetStatsRequest\x1a\x1a.stats.v1.GetStatsResponse\x12P\n" +
+	"\rBatchGetStats\x12\x1e.stats.v1.BatchGetStatsRequest\x1a\x1f.stats.v1.BatchGetStatsResponse\x12L\n" +
+	"\x0eSubscribeStats\x12\x1f.stats.v1.SubscribeStatsRequest\x1a\x17.stats.v1.StreamMessage0\x01B!Z\x1fconsumer/proto/stats/v1;statsv1b\x06proto3"
+
+var (
+	file_stats_v1_stats_service_proto_rawDescOnce sync.Once
+	file_stats_v1_stats_service_proto_rawDescData []byte
+)
+
+func file_stats_v1_stats_service_proto_rawDescGZIP() []byte {
+	file_stats_v1_stats_service_proto_rawDescOnce.Do(func() {
+		file_stats_v1_stats_service_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_stats_v1_stats_service_proto_rawDesc), len(file_stats_v1_stats_service_proto_rawDesc)))
+	})
+	return file_stats_v1_stats_service_proto_rawDescData
+}
+
+var file_stats_v1_stats_service_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_stats_v1_stats_service_proto_goTypes = []any{
+	(*GetStatsRequest)(nil),       // 0: stats.v1.GetStatsRequest
+	(*GetStatsResponse)(nil),      // 1: stats.v1.GetStatsResponse
+	(*BatchGetStatsRequest)(nil),  // 2: stats.v1.BatchGetStatsRequest
+	(*BatchGetStatsResult)(nil),   // 3: stats.v1.BatchGetStatsResult
+	(*BatchGetStatsResponse)(nil), // 4: stats.v1.BatchGetStatsResponse
+	(*SubscribeStatsRequest)(nil), // 5: stats.v1.SubscribeStatsRequest
+	(*Stats)(nil),                 // 6: stats.v1.Stats
+	(*StreamMessage)(nil),         // 7: stats.v1.StreamMessage
+}
+var file_stats_v1_stats_service_proto_depIdxs = []int32{
+	6, // 0: stats.v1.GetStatsResponse.stats:type_name -> stats.v1.Stats
+	6, // 1: stats.v1.BatchGetStatsResult.stats:type_name -> stats.v1.Stats
+	3, // 2: stats.v1.BatchGetStatsResponse.results:type_name -> stats.v1.BatchGetStatsResult
+	0, // 3: stats.v1.StatsService.GetStats:input_type -> stats.v1.GetStatsRequest
+	2, // 4: stats.v1.StatsService.BatchGetStats:input_type -> stats.v1.BatchGetStatsRequest
+	5, // 5: stats.v1.StatsService.SubscribeStats:input_type -> stats.v1.SubscribeStatsRequest
+	1, // 6: stats.v1.StatsService.GetStats:output_type -> stats.v1.GetStatsResponse
+	4, // 7: stats.v1.StatsService.BatchGetStats:output_type -> stats.v1.BatchGetStatsResponse
+	7, // 8: stats.v1.StatsService.SubscribeStats:output_type -> stats.v1.StreamMessage
+	6, // [6:9] is the sub-list for method output_type
+	3, // [3:6] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_stats_v1_stats_service_proto_init() }
+func file_stats_v1_stats_service_proto_init() {
+	if File_stats_v1_stats_service_proto != nil {
+		return
+	}
+	file_stats_v1_stream_proto_init()
+	file_stats_v1_stats_service_proto_msgTypes[5].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_stats_v1_stats_service_proto_rawDesc), len(file_stats_v1_stats_service_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_stats_v1_stats_service_proto_goTypes,
+		DependencyIndexes: file_stats_v1_stats_service_proto_depIdxs,
+		MessageInfos:      file_stats_v1_stats_service_proto_msgTypes,
+	}.Build()
+	File_stats_v1_stats_service_proto = out.File
+	file_stats_v1_stats_service_proto_goTypes = nil
+	file_stats_v1_stats_service_proto_depIdxs = nil
+}
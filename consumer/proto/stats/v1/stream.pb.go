@@ -0,0 +1,463 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: stats/v1/stream.proto
+
+package statsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Aggregated swap stats of a token or pair key in a period window
+type Stats struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Volume  float64                `protobuf:"fixed64,1,opt,name=volume,proto3" json:"volume,omitempty"`
+	TxCount int64                  `protobuf:"varint,2,opt,name=tx_count,json=txCount,proto3" json:"tx_count,omitempty"`
+	// Set for tokens only
+	Flows *Flows `protobuf:"bytes,3,opt,name=flows,proto3" json:"flows,omitempty"`
+	// Volumes in the native units of the tokens of the key, keyed by token
+	Amounts       map[string]float64 `protobuf:"bytes,4,rep,name=amounts,proto3" json:"amounts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_stats_v1_stream_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_stats_v1_stream_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_stats_v1_stream_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Stats) GetVolume() float64 {
+	if x != nil {
+		return x.Volume
+	}
+	return 0
+}
+
+func (x *Stats) GetTxCount() int64 {
+	if x != nil {
+		return x.TxCount
+	}
+	return 0
+}
+
+func (x *Stats) GetFlows() *Flows {
+	if x != nil {
+		return x.Flows
+	}
+	return nil
+}
+
+func (x *Stats) GetAmounts() map[string]float64 {
+	if x != nil {
+		return x.Amounts
+	}
+	return nil
+}
+
+// Volume of a token sold and bought, the net flow is the bought volume less the sold one
+type Flows struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SoldVolume    float64                `protobuf:"fixed64,1,opt,name=sold_volume,json=soldVolume,proto3" json:"sold_volume,omitempty"`
+	BoughtVolume  float64                `protobuf:"fixed64,2,opt,name=bought_volume,json=boughtVolume,proto3" json:"bought_volume,omitempty"`
+	NetFlow       float64                `protobuf:"fixed64,3,opt,name=net_flow,json=netFlow,proto3" json:"net_flow,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Flows) Reset() {
+	*x = Flows{}
+	mi := &file_stats_v1_stream_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Flows) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Flows) ProtoMessage() {}
+
+func (x *Flows) ProtoReflect() protoreflect.Message {
+	mi := &file_stats_v1_stream_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Flows.ProtoReflect.Descriptor instead.
+func (*Flows) Descriptor() ([]byte, []int) {
+	return file_stats_v1_stream_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Flows) GetSoldVolume() float64 {
+	if x != nil {
+		return x.SoldVolume
+	}
+	return 0
+}
+
+func (x *Flows) GetBoughtVolume() float64 {
+	if x != nil {
+		return x.BoughtVolume
+	}
+	return 0
+}
+
+func (x *Flows) GetNetFlow() float64 {
+	if x != nil {
+		return x.NetFlow
+	}
+	return 0
+}
+
+// Subscription to the stats of a token or pair key in a period window
+type Subscription struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Window        string                 `protobuf:"bytes,2,opt,name=window,proto3" json:"window,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subscription) Reset() {
+	*x = Subscription{}
+	mi := &file_stats_v1_stream_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subscription) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subscription) ProtoMessage() {}
+
+func (x *Subscription) ProtoReflect() protoreflect.Message {
+	mi := &file_stats_v1_stream_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subscription.ProtoReflect.Descriptor instead.
+func (*Subscription) Descriptor() ([]byte, []int) {
+	return file_stats_v1_stream_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Subscription) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Subscription) GetWindow() string {
+	if x != nil {
+		return x.Window
+	}
+	return ""
+}
+
+// Binary counterpart of the JSON envelope of the WebSocket messages, see the WebSocket API section of the README.
+// Fields unset in the JSON envelope are left at their zero values
+type StreamMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	V             uint32                 `protobuf:"varint,1,opt,name=v,proto3" json:"v,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Seq           uint64                 `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+	Ts            *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=ts,proto3" json:"ts,omitempty"`
+	Key           string                 `protobuf:"bytes,5,opt,name=key,proto3" json:"key,omitempty"`
+	Window        string                 `protobuf:"bytes,6,opt,name=window,proto3" json:"window,omitempty"`
+	Stats         *Stats                 `protobuf:"bytes,7,opt,name=stats,proto3" json:"stats,omitempty"`
+	TxHash        string                 `protobuf:"bytes,8,opt,name=tx_hash,json=txHash,proto3" json:"tx_hash,omitempty"`
+	Id            string                 `protobuf:"bytes,9,opt,name=id,proto3" json:"id,omitempty"`
+	Action        string                 `protobuf:"bytes,10,opt,name=action,proto3" json:"action,omitempty"`
+	Subscriptions []*Subscription        `protobuf:"bytes,11,rep,name=subscriptions,proto3" json:"subscriptions,omitempty"`
+	Error         string                 `protobuf:"bytes,12,opt,name=error,proto3" json:"error,omitempty"`
+	Code          string                 `protobuf:"bytes,13,opt,name=code,proto3" json:"code,omitempty"`
+	Detail        string                 `protobuf:"bytes,14,opt,name=detail,proto3" json:"detail,omitempty"`
+	PrevSeq       uint64                 `protobuf:"varint,15,opt,name=prev_seq,json=prevSeq,proto3" json:"prev_seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamMessage) Reset() {
+	*x = StreamMessage{}
+	mi := &file_stats_v1_stream_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamMessage) ProtoMessage() {}
+
+func (x *StreamMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_stats_v1_stream_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamMessage.ProtoReflect.Descriptor instead.
+func (*StreamMessage) Descriptor() ([]byte, []int) {
+	return file_stats_v1_stream_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamMessage) GetV() uint32 {
+	if x != nil {
+		return x.V
+	}
+	return 0
+}
+
+func (x *StreamMessage) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *StreamMessage) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *StreamMessage) GetTs() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Ts
+	}
+	return nil
+}
+
+func (x *StreamMessage) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *StreamMessage) GetWindow() string {
+	if x != nil {
+		return x.Window
+	}
+	return ""
+}
+
+func (x *StreamMessage) GetStats() *Stats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *StreamMessage) GetTxHash() string {
+	if x != nil {
+		return x.TxHash
+	}
+	return ""
+}
+
+func (x *StreamMessage) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *StreamMessage) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *StreamMessage) GetSubscriptions() []*Subscription {
+	if x != nil {
+		return x.Subscriptions
+	}
+	return nil
+}
+
+func (x *StreamMessage) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *StreamMessage) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *StreamMessage) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+func (x *StreamMessage) GetPrevSeq() uint64 {
+	if x != nil {
+		return x.PrevSeq
+	}
+	return 0
+}
+
+var File_stats_v1_stream_proto protoreflect.FileDescriptor
+
+const file_stats_v1_stream_proto_rawDesc = "" +
+	"\n" +
+	"\x15stats/v1/stream.proto\x12\bstats.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xd5\x01\n" +
+	"\x05Stats\x12\x16\n" +
+	"\x06volume\x18\x01 \x01(\x01R\x06volume\x12\x19\n" +
+	"\btx_count\x18\x02 \x01(\x03R\atxCount\x12%\n" +
+	"\x05flows\x18\x03 \x01(\v2\x0f.stats.v1.FlowsR\x05flows\x126\n" +
+	"\aamounts\x18\x04 \x03(\v2\x1c.stats.v1.Stats.AmountsEntryR\aamounts\x1a:\n" +
+	"\fAmountsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\"h\n" +
+	"\x05Flows\x12\x1f\n" +
+	"\vsold_volume\x18\x01 \x01(\x01R\n" +
+	"soldVolume\x12#\n" +
+	"\rbought_volume\x18\x02 \x01(\x01R\fboughtVolume\x12\x19\n" +
+	"\bnet_flow\x18\x03 \x01(\x01R\anetFlow\"8\n" +
+	"\fSubscription\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x16\n" +
+	"\x06window\x18\x02 \x01(\tR\x06window\"\x9c\x03\n" +
+	"\rStreamMessage\x12\f\n" +
+	"\x01v\x18\x01 \x01(\rR\x01v\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x10\n" +
+	"\x03seq\x18\x03 \x01(\x04R\x03seq\x12*\n" +
+	"\x02ts\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x02ts\x12\x10\n" +
+	"\x03key\x18\x05 \x01(\tR\x03key\x12\x16\n" +
+	"\x06window\x18\x06 \x01(\tR\x06window\x12%\n" +
+	"\x05stats\x18\a \x01(\v2\x0f.stats.v1.StatsR\x05stats\x12\x17\n" +
+	"\atx_hash\x18\b \x01(\tR\x06txHash\x12\x0e\n" +
+	"\x02id\x18\t \x01(\tR\x02id\x12\x16\n" +
+	"\x06action\x18\n" +
+	" \x01(\tR\x06action\x12<\n" +
+	"\rsubscriptions\x18\v \x03(\v2\x16.stats.v1.SubscriptionR\rsubscriptions\x12\x14\n" +
+	"\x05error\x18\f \x01(\tR\x05error\x12\x12\n" +
+	"\x04code\x18\r \x01(\tR\x04code\x12\x16\n" +
+	"\x06detail\x18\x0e \x01(\tR\x06detail\x12\x19\n" +
+	"\bprev_seq\x18\x0f \x01(\x04R\aprevSeqB!Z\x1fconsumer/proto/stats/v1;statsv1b\x06proto3"
+
+var (
+	file_stats_v1_stream_proto_rawDescOnce sync.Once
+	file_stats_v1_stream_proto_rawDescData []byte
+)
+
+func file_stats_v1_stream_proto_rawDescGZIP() []byte {
+	file_stats_v1_stream_proto_rawDescOnce.Do(func() {
+		file_stats_v1_stream_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_stats_v1_stream_proto_rawDesc), len(file_stats_v1_stream_proto_rawDesc)))
+	})
+	return file_stats_v1_stream_proto_rawDescData
+}
+
+var file_stats_v1_stream_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_stats_v1_stream_proto_goTypes = []any{
+	(*Stats)(nil),                 // 0: stats.v1.Stats
+	(*Flows)(nil),                 // 1: stats.v1.Flows
+	(*Subscription)(nil),          // 2: stats.v1.Subscription
+	(*StreamMessage)(nil),         // 3: stats.v1.StreamMessage
+	nil,                           // 4: stats.v1.Stats.AmountsEntry
+	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
+}
+var file_stats_v1_stream_proto_depIdxs = []int32{
+	1, // 0: stats.v1.Stats.flows:type_name -> stats.v1.Flows
+	4, // 1: stats.v1.Stats.amounts:type_name -> stats.v1.Stats.AmountsEntry
+	5, // 2: stats.v1.StreamMessage.ts:type_name -> google.protobuf.Timestamp
+	0, // 3: stats.v1.StreamMessage.stats:type_name -> stats.v1.Stats
+	2, // 4: stats.v1.StreamMessage.subscriptions:type_name -> stats.v1.Subscription
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_stats_v1_stream_proto_init() }
+func file_stats_v1_stream_proto_init() {
+	if File_stats_v1_stream_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_stats_v1_stream_proto_rawDesc), len(file_stats_v1_stream_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_stats_v1_stream_proto_goTypes,
+		DependencyIndexes: file_stats_v1_stream_proto_depIdxs,
+		MessageInfos:      file_stats_v1_stream_proto_msgTypes,
+	}.Build()
+	File_stats_v1_stream_proto = out.File
+	file_stats_v1_stream_proto_goTypes = nil
+	file_stats_v1_stream_proto_depIdxs = nil
+}
@@ -1,13 +1,26 @@
 package producer
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"producer/internal/tracing"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("producer/internal/producer")
+
+// Events can implement this interface to enrich the span started for them
+type spanAttributer interface {
+	SpanAttributes() []attribute.KeyValue
+}
+
 type Client[T any] struct {
 	ch            chan T
 	cfg           Config
@@ -31,22 +44,44 @@ func New[T any](ch chan T, cfg Config) (*Client[T], error) {
 	return &Client[T]{ch: ch, cfg: cfg, KafkaProducer: producer}, nil
 }
 
+// Produce every event from the channel in its own span.
+// The span context is propagated to the consumers via Kafka headers
+// and the span is ended once the delivery report arrives (see LogErrors)
 func (c *Client[T]) ProduceMessagesFromChannel() {
 	for event := range c.ch {
+		ctx, span := tracer.Start(context.Background(), "swap.produce",
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(
+				attribute.String("messaging.system", "kafka"),
+				attribute.String("messaging.destination.name", c.cfg.Topic),
+			),
+		)
+		if a, ok := any(event).(spanAttributer); ok {
+			span.SetAttributes(a.SpanAttributes()...)
+		}
+
 		eventBytes, err := json.Marshal(event)
 		if err != nil {
 			log.Printf("failed to marshal an event: %v", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to marshal an event")
+			span.End()
 			continue
 		}
 
 		msg := &kafka.Message{
 			TopicPartition: kafka.TopicPartition{Topic: &c.cfg.Topic},
 			Value:          eventBytes,
+			Opaque:         span,
 		}
+		tracing.InjectKafkaHeaders(ctx, msg)
 
 		err = c.KafkaProducer.Produce(msg, nil)
 		if err != nil {
 			log.Printf("Failed to produce message: %v", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to produce message")
+			span.End()
 		}
 	}
 }
@@ -55,8 +90,20 @@ func (c *Client[T]) LogErrors() {
 	for e := range c.KafkaProducer.Events() {
 		switch ev := e.(type) {
 		case *kafka.Message:
+			span, _ := ev.Opaque.(trace.Span)
 			if ev.TopicPartition.Error != nil {
 				log.Printf("Error producing message: %v", ev.TopicPartition.Error)
+				if span != nil {
+					span.RecordError(ev.TopicPartition.Error)
+					span.SetStatus(codes.Error, "failed to deliver message")
+				}
+			}
+			if span != nil {
+				span.SetAttributes(
+					attribute.Int("messaging.kafka.destination.partition", int(ev.TopicPartition.Partition)),
+					attribute.Int64("messaging.kafka.message.offset", int64(ev.TopicPartition.Offset)),
+				)
+				span.End()
 			}
 		}
 	}
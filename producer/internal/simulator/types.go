@@ -1,6 +1,10 @@
 package simulator
 
-import "time"
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
 
 // SwapEvent represents a single swap event with base fields
 type SwapEvent struct {
@@ -13,6 +17,16 @@ type SwapEvent struct {
 	Timestamp  time.Time `json:"timestamp"`
 }
 
+// Attributes of the span started for the event by the producer
+func (e *SwapEvent) SpanAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("swap.tx_hash", e.TxHash),
+		attribute.String("swap.token_from", e.TokenFrom),
+		attribute.String("swap.token_to", e.TokenTo),
+		attribute.Float64("swap.usd_value", e.UsdValue),
+	}
+}
+
 // Token info to simulate swaps
 type TokenInfo struct {
 	Name     string  `json:"name"`
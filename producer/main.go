@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"producer/internal/producer"
 	"producer/internal/simulator"
+	"producer/internal/tracing"
 	"strconv"
 )
 
@@ -18,6 +20,16 @@ func main() {
 		log.Fatalf("failed to convert string %s to float64: %v", swapEventsPerSecondStr, err)
 	}
 
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Exporter:     os.Getenv("TRACING_EXPORTER"),
+		OtlpEndpoint: os.Getenv("TRACING_OTLP_ENDPOINT"),
+		ServiceName:  "producer",
+	})
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	var swapChannel = make(chan *simulator.SwapEvent, 1000)
 	var cfg = producer.Config{
 		Brokers: kafkaBrokers,
@@ -2,18 +2,31 @@ package main
 
 import (
 	"log"
+	"net/http"
+	"os"
 
 	"github.com/gorilla/websocket"
 )
 
 func main() {
 	serverURL := "ws://localhost:8082/ws"
-	conn, _, err := websocket.DefaultDialer.Dial(serverURL, nil)
+	// API key for gateways with authentication enabled
+	header := http.Header{}
+	if apiKey := os.Getenv("WS_API_KEY"); apiKey != "" {
+		header.Set("X-API-Key", apiKey)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(serverURL, header)
 	if err != nil {
 		log.Println("Error connecting to WebSocket server: ", err)
 	}
 	defer conn.Close()
 
+	// Subscribe to all tokens and pairs in all period windows
+	err = conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"1","action":"subscribe","keys":["*","*-*"]}`))
+	if err != nil {
+		log.Println("Error subscribing: ", err)
+	}
+
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {